@@ -0,0 +1,117 @@
+package httpgrace
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+)
+
+type adminConfig struct {
+	addr      string
+	configure func(mux *http.ServeMux)
+}
+
+// WithAdminServer starts a second server on addr, next to the main one, to
+// expose operational endpoints such as /debug/pprof, /metrics or /healthz
+// isolated from the public traffic. The routes are registered by configure.
+// The admin server starts and shuts down together with the main server,
+// sharing its signals and shutdown timeout.
+func WithAdminServer(addr string, configure func(mux *http.ServeMux)) Option {
+	return func(cfg *serverConfig) {
+		cfg.admin = &adminConfig{
+			addr:      addr,
+			configure: configure,
+		}
+	}
+}
+
+// startSidecars starts the servers running alongside the main one,
+// such as the admin server, returning their listeners.
+func (s *Server) startSidecars() ([]net.Listener, error) {
+	var lns []net.Listener
+
+	if s.config.admin != nil {
+		mux := http.NewServeMux()
+		if s.config.admin.configure != nil {
+			s.config.admin.configure(mux)
+		}
+		ln, err := s.startSidecar("admin", s.config.admin.addr, mux)
+		if err != nil {
+			return nil, err
+		}
+		lns = append(lns, ln)
+	}
+
+	if s.config.autocert != nil && s.config.autocertChallengeAddr != "" {
+		handler := s.config.autocert.HTTPHandler(nil)
+		ln, err := s.startSidecar("ACME challenge", s.config.autocertChallengeAddr, handler)
+		if err != nil {
+			s.closeSidecars()
+			return nil, err
+		}
+		lns = append(lns, ln)
+	}
+
+	return lns, nil
+}
+
+// startSidecar binds and starts a sidecar server in the background,
+// reusing the listener of the previous process on a graceful restart.
+func (s *Server) startSidecar(name, addr string, handler http.Handler) (net.Listener, error) {
+	sidecar := &Server{
+		Server: &http.Server{
+			Addr:    addr,
+			Handler: handler,
+		},
+		config: s.config,
+	}
+
+	ln, err := s.listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s.log().Info("starting "+name+" server", "addr", ln.Addr().String())
+	s.sidecars = append(s.sidecars, sidecar)
+
+	go func() {
+		err := sidecar.Server.Serve(ln)
+		if err != nil && !sidecar.isShutdownErr(err) {
+			s.log().Error(name+" server error", "error", err)
+		}
+	}()
+
+	return ln, nil
+}
+
+// closeSidecars forcibly closes the sidecar servers.
+func (s *Server) closeSidecars() {
+	for _, sidecar := range s.sidecars {
+		sidecar.Server.Close()
+	}
+}
+
+// shutdownServers gracefully shuts down the main server and the sidecar
+// servers concurrently.
+func (s *Server) shutdownServers(ctx context.Context) error {
+	errs := make(chan error, len(s.sidecars))
+	for _, sidecar := range s.sidecars {
+		sidecar.shuttingDown.Store(true)
+		go func() {
+			errs <- sidecar.Server.Shutdown(ctx)
+		}()
+	}
+
+	err := s.Server.Shutdown(ctx)
+	for range s.sidecars {
+		err = errors.Join(err, <-errs)
+	}
+	return err
+}
+
+// closeServers forcibly closes the main server and the sidecar servers.
+func (s *Server) closeServers() {
+	s.closeSidecars()
+	s.Server.Close()
+}
@@ -0,0 +1,56 @@
+package httpgrace
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"slices"
+)
+
+var errAutocertWithFiles = errors.New("httpgrace: WithAutocert cannot be used with certificate files")
+
+// CertManager obtains and renews TLS certificates automatically.
+// It is implemented by *autocert.Manager from golang.org/x/crypto/acme/autocert.
+type CertManager interface {
+	GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error)
+	HTTPHandler(fallback http.Handler) http.Handler
+}
+
+// WithAutocert serves TLS with the certificates obtained by m, e.g. an
+// *autocert.Manager getting them from Let's Encrypt. The manager should be
+// given a Cache, such as autocert.DirCache, so that the certificates survive
+// restarts without hitting the CA rate limits. The TLS-ALPN-01 challenge is
+// served by the TLS server itself; to also serve the HTTP-01 challenge, see
+// WithAutocertHTTPChallenge. Other TLS settings can still be customized with
+// a ServerOption changing the http.Server TLSConfig.
+func WithAutocert(m CertManager) Option {
+	return func(cfg *serverConfig) {
+		cfg.autocert = m
+	}
+}
+
+// WithAutocertHTTPChallenge serves the ACME HTTP-01 challenge of the
+// WithAutocert manager on addr (usually ":80"), redirecting other requests to
+// HTTPS. The challenge server shuts down together with the main server.
+func WithAutocertHTTPChallenge(addr string) Option {
+	return func(cfg *serverConfig) {
+		cfg.autocertChallengeAddr = addr
+	}
+}
+
+// installAutocert sets the certificate manager in the TLS configuration of
+// the underlying http.Server.
+func (s *Server) installAutocert() {
+	// the config may be shared with the caller, through WithServerOptions
+	config := &tls.Config{}
+	if s.Server.TLSConfig != nil {
+		config = s.Server.TLSConfig.Clone()
+	}
+	config.GetCertificate = s.config.autocert.GetCertificate
+
+	// enable the TLS-ALPN-01 challenge
+	if !slices.Contains(config.NextProtos, "acme-tls/1") {
+		config.NextProtos = append(slices.Clip(config.NextProtos), "acme-tls/1")
+	}
+	s.Server.TLSConfig = config
+}
@@ -0,0 +1,39 @@
+package httpgrace
+
+import (
+	"crypto/tls"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+type stubCertManager struct{}
+
+func (stubCertManager) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return nil, nil
+}
+
+func (stubCertManager) HTTPHandler(fallback http.Handler) http.Handler {
+	return fallback
+}
+
+func TestAutocertKeepsConfig(t *testing.T) {
+	protos := make([]string, 1, 2)
+	protos[0] = "h2"
+	config := &tls.Config{NextProtos: protos}
+	srv := NewServer(http.NotFoundHandler(),
+		WithAutocert(stubCertManager{}),
+		WithServerOptions(func(s *http.Server) {
+			s.TLSConfig = config
+		}))
+
+	srv.installAutocert()
+
+	if srv.TLSConfig.GetCertificate == nil ||
+		!slices.Equal(srv.TLSConfig.NextProtos, []string{"h2", "acme-tls/1"}) {
+		t.Fatal("certificate manager not installed in the TLS config")
+	}
+	if config.GetCertificate != nil || len(config.NextProtos) != 1 || protos[:2][1] != "" {
+		t.Fatal("the TLSConfig of the caller was modified")
+	}
+}
@@ -0,0 +1,83 @@
+package httpgrace
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WaitGroup tracks the background goroutines spawned by the handlers that
+// outlive their requests, so that the graceful shutdown waits for them.
+// It has the same methods as sync.WaitGroup, and also counts the pending
+// goroutines.
+type WaitGroup struct {
+	wg      sync.WaitGroup
+	pending atomic.Int64
+}
+
+// Add adds delta to the WaitGroup counter, see sync.WaitGroup.Add.
+func (wg *WaitGroup) Add(delta int) {
+	wg.pending.Add(int64(delta))
+	wg.wg.Add(delta)
+}
+
+// Done decrements the WaitGroup counter by one.
+func (wg *WaitGroup) Done() {
+	wg.Add(-1)
+}
+
+// Wait blocks until the WaitGroup counter is zero.
+func (wg *WaitGroup) Wait() {
+	wg.wg.Wait()
+}
+
+// Go runs f in a new goroutine tracked by the WaitGroup.
+func (wg *WaitGroup) Go(f func()) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		f()
+	}()
+}
+
+// Pending returns the number of goroutines not done yet.
+func (wg *WaitGroup) Pending() int {
+	return int(wg.pending.Load())
+}
+
+// WaitGroup returns the WaitGroup the server waits for during shutdown,
+// after the connections have been drained and within the shutdown timeout.
+// Handlers should use it for the goroutines that outlive their requests:
+//
+//	srv.WaitGroup().Go(func() {
+//		sendWebhook(event)
+//	})
+func (s *Server) WaitGroup() *WaitGroup {
+	return &s.background
+}
+
+// waitBackground waits for the background goroutines until ctx is done.
+func (s *Server) waitBackground(ctx context.Context) {
+	if s.background.Pending() == 0 {
+		return
+	}
+
+	s.log().Info("waiting for background goroutines",
+		"goroutines", s.background.Pending())
+
+	start := time.Now()
+	done := make(chan struct{})
+	go func() {
+		s.background.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		s.log().Info("background goroutines completed", "duration", time.Since(start))
+	case <-ctx.Done():
+		s.log().Warn("background goroutines still running after shutdown timeout",
+			"goroutines", s.background.Pending())
+	}
+}
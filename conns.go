@@ -0,0 +1,61 @@
+package httpgrace
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+const activeConnectionsLogInterval = time.Second
+
+// trackConnState counts the active connections, calling the ConnState hook
+// possibly set with a ServerOption as well.
+func (s *Server) trackConnState() {
+	next := s.Server.ConnState
+
+	s.Server.ConnState = func(c net.Conn, state http.ConnState) {
+		switch state {
+		case http.StateNew:
+			s.activeConns.Add(1)
+		case http.StateHijacked, http.StateClosed:
+			s.activeConns.Add(-1)
+		}
+
+		if next != nil {
+			next(c, state)
+		}
+	}
+}
+
+// ActiveConnections returns the number of connections currently open,
+// hijacked connections excluded.
+func (s *Server) ActiveConnections() int {
+	return int(s.activeConns.Load())
+}
+
+// reportActiveConnections periodically logs the number of connections still
+// open until they are all closed or the returned stop function is called.
+func (s *Server) reportActiveConnections() (stop func()) {
+	done := make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(activeConnectionsLogInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			active := s.ActiveConnections()
+			if active == 0 {
+				return
+			}
+			s.log().Info("waiting for connections to close", "connections", active)
+		}
+	}()
+
+	return func() { close(done) }
+}
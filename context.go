@@ -0,0 +1,89 @@
+package httpgrace
+
+import (
+	"context"
+	"net"
+)
+
+// serverContextKey is the context key of the Server handling a request.
+type serverContextKey struct{}
+
+// ShuttingDown reports whether the server handling the request of ctx is
+// shutting down, e.g. to set "Connection: close" or to reject new long-poll
+// subscriptions early. It turns true as soon as the shutdown is triggered,
+// when ReadinessHandler starts failing, and stays true during the drain delay
+// although requests are still served normally then. It reports false for
+// contexts that do not come from a request of a Server.
+func ShuttingDown(ctx context.Context) bool {
+	s, ok := ctx.Value(serverContextKey{}).(*Server)
+	return ok && s.shuttingDown.Load()
+}
+
+// WithBaseContext sets the base context of the incoming requests,
+// see http.Server.BaseContext.
+func WithBaseContext(ctx context.Context) Option {
+	return func(cfg *serverConfig) {
+		cfg.baseCtx = ctx
+	}
+}
+
+// WithConnContext sets a function to modify the context used for each new
+// connection, see http.Server.ConnContext.
+func WithConnContext(fn func(ctx context.Context, c net.Conn) context.Context) Option {
+	return func(cfg *serverConfig) {
+		cfg.connContext = fn
+	}
+}
+
+// WithCancelRequestsOnDrain cancels the context of the requests still being
+// handled when the server starts draining the connections, so that
+// long-running handlers can abort early instead of holding up the shutdown.
+// The contexts are not cancelled during the drain delay or the hooks that
+// precede the drain, so requests are not disrupted until then.
+func WithCancelRequestsOnDrain() Option {
+	return func(cfg *serverConfig) {
+		cfg.cancelOnDrain = true
+	}
+}
+
+// setupContexts sets the request base and connection contexts of the
+// underlying http.Server, wrapping the BaseContext hook possibly set with a
+// ServerOption, which takes precedence over WithBaseContext. The base
+// context carries the server, for ShuttingDown.
+func (s *Server) setupContexts() {
+	next := s.Server.BaseContext
+	if next == nil {
+		base := s.config.baseCtx
+		if base == nil {
+			base = context.Background()
+		}
+		next = func(net.Listener) context.Context { return base }
+	}
+
+	var drain context.Context
+	if s.config.cancelOnDrain {
+		drain, s.cancelRequests = context.WithCancel(context.Background())
+	}
+
+	s.Server.BaseContext = func(ln net.Listener) context.Context {
+		base := next(ln)
+		if drain != nil {
+			var cancel context.CancelFunc
+			base, cancel = context.WithCancel(base)
+			context.AfterFunc(drain, cancel)
+		}
+		return context.WithValue(base, serverContextKey{}, s)
+	}
+	if s.Server.ConnContext == nil {
+		s.Server.ConnContext = s.config.connContext
+	}
+}
+
+// cancelRequestContexts cancels the context of the in-flight requests,
+// if enabled with WithCancelRequestsOnDrain.
+func (s *Server) cancelRequestContexts() {
+	if s.cancelRequests != nil {
+		s.log().Info("cancelling in-flight request contexts", "requests", s.InFlight())
+		s.cancelRequests()
+	}
+}
@@ -0,0 +1,66 @@
+package httpgrace
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+type testContextKey struct{}
+
+func TestBaseContextServerOption(t *testing.T) {
+	ready := make(chan struct{})
+	sigs := make(chan os.Signal, 1)
+	type result struct {
+		value        any
+		shuttingDown bool
+		cancelled    bool
+	}
+	results := make(chan result, 1)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		got := result{value: ctx.Value(testContextKey{})}
+		select {
+		case <-ctx.Done():
+			got.cancelled = true
+		case <-time.After(5 * time.Second):
+		}
+		got.shuttingDown = ShuttingDown(ctx)
+		results <- got
+	})
+
+	srv := NewServer(handler,
+		WithSignalChannel(sigs),
+		WithReady(ready),
+		WithCancelRequestsOnDrain(),
+		WithServerOptions(func(s *http.Server) {
+			s.BaseContext = func(net.Listener) context.Context {
+				return context.WithValue(context.Background(), testContextKey{}, "set by option")
+			}
+		}))
+	errs := serveAsync(t, srv, listen(t), ready)
+
+	go http.Get("http://" + srv.ListenAddr().String())
+	for srv.InFlight() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	sigs <- syscall.SIGTERM
+
+	got := <-results
+	if got.value != "set by option" {
+		t.Errorf("context value = %v, want the one of the BaseContext option", got.value)
+	}
+	if !got.cancelled {
+		t.Error("request context not cancelled on drain")
+	}
+	if !got.shuttingDown {
+		t.Error("ShuttingDown() = false, want true")
+	}
+	if err := waitServe(t, errs); err != nil {
+		t.Fatalf("Serve() error = %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+package httpgrace
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// DrainPolicy defines how requests are handled once shutdown has been
+// triggered, while the server is still serving (e.g. during the
+// WithBeforeShutdown hook).
+type DrainPolicy int
+
+const (
+	// ServeNormally serves the requests as usual.
+	ServeNormally DrainPolicy = iota
+	// CloseConnection serves the requests adding a "Connection: close" header,
+	// so that clients stop reusing the connection to the draining server.
+	CloseConnection
+	// Reject503 rejects the requests with 503 Service Unavailable.
+	Reject503
+)
+
+// WithDrainRequestPolicy sets how requests are handled while the server is
+// shutting down (default: ServeNormally).
+func WithDrainRequestPolicy(policy DrainPolicy) Option {
+	return func(cfg *serverConfig) {
+		cfg.drainPolicy = policy
+	}
+}
+
+// applyDrainPolicy handles the requests received during shutdown according
+// to the configured DrainPolicy.
+func (s *Server) applyDrainPolicy(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.shuttingDown.Load() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		switch s.config.drainPolicy {
+		case CloseConnection:
+			w.Header().Set("Connection", "close")
+		case Reject503:
+			w.Header().Set("Connection", "close")
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// WithDrainDelay waits d after the shutdown is triggered before the server
+// stops accepting connections, while it keeps serving requests normally.
+// This gives load balancers time to stop routing traffic to the server, which
+// meanwhile reports not ready. A second signal interrupts the delay.
+// The delay is not counted in the shutdown timeout, but in the drain timeout
+// (see WithDrainTimeout).
+func WithDrainDelay(d time.Duration) Option {
+	return func(cfg *serverConfig) {
+		cfg.drainDelay = d
+	}
+}
+
+// waitDrainDelay waits for the drain delay, or until it is interrupted by
+// another shutdown signal or ctx is done.
+func (s *Server) waitDrainDelay(ctx context.Context, interrupt <-chan struct{}) {
+	s.log().Info("drain delay started", "delay", s.config.drainDelay)
+
+	timer := time.NewTimer(s.config.drainDelay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		s.log().Info("drain delay completed")
+	case <-interrupt:
+		s.log().Info("drain delay interrupted")
+	case <-ctx.Done():
+		s.log().Info("drain delay cut short by the drain timeout")
+	}
+}
+
+// WithDisableKeepAlivesOnShutdown disables HTTP keep-alives as soon as the
+// shutdown is triggered, before the drain delay and hooks, closing the idle
+// connections and making clients open new ones elsewhere instead of reusing
+// the ones to this server.
+func WithDisableKeepAlivesOnShutdown() Option {
+	return func(cfg *serverConfig) {
+		cfg.disableKeepAlivesOnShutdown = true
+	}
+}
+
+// WithDisableKeepAlives disables HTTP keep-alives from the start, so that
+// every connection serves a single request.
+func WithDisableKeepAlives() Option {
+	return func(cfg *serverConfig) {
+		cfg.disableKeepAlives = true
+	}
+}
@@ -0,0 +1,79 @@
+package httpgrace
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestDisableKeepAlivesOnShutdown(t *testing.T) {
+	const drainDelay = time.Second
+
+	tests := []struct {
+		name       string
+		opts       []Option
+		wantClosed bool
+	}{
+		{
+			name:       "enabled",
+			opts:       []Option{WithDisableKeepAlivesOnShutdown()},
+			wantClosed: true,
+		},
+		{
+			name: "disabled",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ready := make(chan struct{})
+			sigs := make(chan os.Signal, 1)
+			opts := append([]Option{
+				WithSignalChannel(sigs),
+				WithReady(ready),
+				WithDrainDelay(drainDelay),
+			}, tt.opts...)
+			srv := NewServer(http.NotFoundHandler(), opts...)
+			errs := serveAsync(t, srv, listen(t), ready)
+
+			// leave an idle keep-alive connection after a first request
+			conn, err := net.Dial("tcp", srv.ListenAddr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			fmt.Fprintf(conn, "GET / HTTP/1.1\r\nHost: test\r\n\r\n")
+			r := bufio.NewReader(conn)
+			resp, err := http.ReadResponse(r, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			sigs <- syscall.SIGTERM
+			start := time.Now()
+
+			conn.SetReadDeadline(time.Now().Add(drainDelay / 2))
+			_, err = r.ReadByte()
+			closed := err != nil && !errors.Is(err, os.ErrDeadlineExceeded)
+			if closed != tt.wantClosed {
+				t.Fatalf("idle connection closed during the drain delay = %v, want %v (error: %v)",
+					closed, tt.wantClosed, err)
+			}
+			if closed && time.Since(start) >= drainDelay {
+				t.Fatalf("idle connection closed after %v, not before the drain delay ended", time.Since(start))
+			}
+
+			conn.Close()
+			if err := waitServe(t, errs); err != nil {
+				t.Fatalf("Serve() error = %v", err)
+			}
+		})
+	}
+}
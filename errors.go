@@ -0,0 +1,51 @@
+package httpgrace
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrShutdownTimeout is returned when the connections did not drain within
+// the shutdown timeout. The returned error also wraps context.DeadlineExceeded.
+var ErrShutdownTimeout = errors.New("httpgrace: shutdown timed out")
+
+// ErrForceClosed is returned when the graceful shutdown timed out and the
+// remaining connections were forcibly closed (see WithForceCloseOnTimeout).
+var ErrForceClosed = errors.New("httpgrace: connections forcibly closed after shutdown timeout")
+
+// ErrImmediateShutdown is returned when a signal with the Immediate action
+// closed the connections without draining them (see WithSignalAction).
+var ErrImmediateShutdown = errors.New("httpgrace: immediate shutdown, connections forcibly closed")
+
+// ErrAlreadyStarted is returned when Server.Start is called more than once.
+var ErrAlreadyStarted = errors.New("httpgrace: server already started")
+
+// Phase is the lifecycle phase during which a ServeError happened.
+type Phase string
+
+const (
+	// PhaseServe is the phase during which the server serves requests.
+	PhaseServe Phase = "serve"
+	// PhaseShutdown is the graceful shutdown of the server.
+	PhaseShutdown Phase = "shutdown"
+)
+
+// ServeError is returned by the serving methods, wrapping the error of the
+// phase in which it happened: a listener failing while serving, or the
+// shutdown failing. Use errors.Is on it to check for ErrShutdownTimeout,
+// ErrForceClosed or the underlying cause.
+type ServeError struct {
+	Phase Phase
+	Err   error
+}
+
+func (e *ServeError) Error() string {
+	return fmt.Sprintf("httpgrace: %s: %v", e.Phase, e.Err)
+}
+
+func (e *ServeError) Unwrap() error {
+	return e.Err
+}
+
+// errNoListeners is returned when ServeAll is called without listeners.
+var errNoListeners = errors.New("httpgrace: no listeners to serve on")
@@ -0,0 +1,119 @@
+package httpgrace
+
+import (
+	"errors"
+	"net"
+	"os"
+	"os/signal"
+	"slices"
+)
+
+// Group runs several Servers under a single signal handler, shutting them
+// all down together when a signal is received or when any of them stops,
+// e.g. because it failed to start.
+// Servers added to a Group don't handle signals on their own: every signal
+// the Group receives is forwarded to all of them, so that a second signal
+// still interrupts their drain delay, and the actions set with
+// WithSignalAction still apply.
+type Group struct {
+	signals []os.Signal
+	members []groupMember
+}
+
+type groupMember struct {
+	srv     *Server
+	serve   func() error
+	signals chan os.Signal
+}
+
+// NewGroup creates a Group shutting down on the given signals
+// (default: SIGINT, SIGTERM).
+func NewGroup(signals ...os.Signal) *Group {
+	if len(signals) == 0 {
+		signals = defaultConfig().signals
+	}
+	return &Group{signals: signals}
+}
+
+// ListenAndServe adds a server listening on addr to the group.
+func (g *Group) ListenAndServe(srv *Server, addr string) {
+	g.add(srv, func() error { return srv.ListenAndServe(addr) })
+}
+
+// ListenAndServeTLS adds a TLS server listening on addr to the group.
+func (g *Group) ListenAndServeTLS(srv *Server, addr, certFile, keyFile string) {
+	g.add(srv, func() error { return srv.ListenAndServeTLS(addr, certFile, keyFile) })
+}
+
+// Serve adds a server serving on the given listener to the group.
+func (g *Group) Serve(srv *Server, ln net.Listener) {
+	g.add(srv, func() error { return srv.Serve(ln) })
+}
+
+func (g *Group) add(srv *Server, serve func() error) {
+	signals := make(chan os.Signal, 1)
+	srv.config.signals = nil
+	srv.config.signalChannel = signals
+	g.members = append(g.members, groupMember{srv: srv, serve: serve, signals: signals})
+}
+
+// Run starts all the servers of the group and blocks until all of them have
+// stopped, returning their joined errors.
+func (g *Group) Run() error {
+	// the signals with an action set on a member are handled as well
+	signals := slices.Clone(g.signals)
+	for _, m := range g.members {
+		signals = append(signals, m.srv.config.notifySignals()...)
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, signals...)
+	defer signal.Stop(sigChan)
+
+	errs := make(chan error, len(g.members))
+	for _, m := range g.members {
+		go func() { errs <- m.serve() }()
+	}
+
+	var all []error
+	remaining := len(g.members)
+	if remaining == 0 {
+		return nil
+	}
+
+	// stopping is set once the shutdown of the servers has been triggered
+	stopping := false
+	for remaining > 0 {
+		select {
+		case sig := <-sigChan:
+			stopping = true
+			g.forward(sig)
+		case err := <-errs:
+			remaining--
+			all = append(all, err)
+			if !stopping {
+				stopping = true
+				g.shutdown(SignalGroupMemberStopped)
+			}
+		}
+	}
+	return errors.Join(all...)
+}
+
+// forward sends sig to all the servers of the group. Like signal.Notify, it
+// does not block on the servers that are not reading their signals.
+func (g *Group) forward(sig os.Signal) {
+	for _, m := range g.members {
+		select {
+		case m.signals <- sig:
+		default:
+		}
+	}
+}
+
+// shutdown triggers the graceful shutdown of all the servers of the group.
+func (g *Group) shutdown(sig os.Signal) {
+	for _, m := range g.members {
+		m.srv.triggerShutdown(sig)
+	}
+}
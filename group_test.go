@@ -0,0 +1,49 @@
+//go:build unix
+
+package httpgrace
+
+import (
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestGroupSecondSignalInterruptsDrainDelay(t *testing.T) {
+	const drainDelay = 3 * time.Second
+
+	readyA, readyB := make(chan struct{}), make(chan struct{})
+	a := NewServer(http.NotFoundHandler(), WithReady(readyA), WithDrainDelay(drainDelay))
+	b := NewServer(http.NotFoundHandler(), WithReady(readyB), WithDrainDelay(drainDelay))
+
+	g := NewGroup(syscall.SIGUSR1)
+	g.Serve(a, listen(t))
+	g.Serve(b, listen(t))
+
+	errs := make(chan error, 1)
+	go func() { errs <- g.Run() }()
+	for _, ready := range []chan struct{}{readyA, readyB} {
+		select {
+		case <-ready:
+		case <-time.After(5 * time.Second):
+			t.Fatal("group not ready")
+		}
+	}
+
+	start := time.Now()
+	syscall.Kill(syscall.Getpid(), syscall.SIGUSR1)
+	for !a.shuttingDown.Load() || !b.shuttingDown.Load() {
+		if time.Since(start) > 2*time.Second {
+			t.Fatal("first signal did not start the shutdown of the members")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	syscall.Kill(syscall.Getpid(), syscall.SIGUSR1)
+
+	if err := waitServe(t, errs); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= drainDelay {
+		t.Fatalf("Run returned after %v, the second signal did not interrupt the drain delay", elapsed)
+	}
+}
@@ -0,0 +1,27 @@
+package httpgrace
+
+import "net/http"
+
+// WithH2C enables HTTP/2 over cleartext TCP (h2c), e.g. behind proxies or
+// sidecars speaking HTTP/2 without TLS, or for gRPC. HTTP/1 is still served,
+// and the graceful shutdown drains the HTTP/2 streams as well, sending GOAWAY
+// to the clients.
+//
+// Only HTTP/2 with prior knowledge is supported, the deprecated
+// "Upgrade: h2c" mechanism is not. Idle h2c connections are closed by
+// Shutdown, and otherwise after the server IdleTimeout like for HTTP/1.
+func WithH2C() Option {
+	return func(cfg *serverConfig) {
+		cfg.h2c = true
+	}
+}
+
+// enableH2C configures the protocols of the underlying http.Server to serve
+// h2c, keeping HTTP/1 and HTTP/2 over TLS enabled.
+func (s *Server) enableH2C() {
+	var protocols http.Protocols
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(true)
+	s.Server.Protocols = &protocols
+}
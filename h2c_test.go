@@ -0,0 +1,64 @@
+package httpgrace
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestShutdownDrainsH2CStreams(t *testing.T) {
+	ready := make(chan struct{})
+	sigs := make(chan os.Signal, 1)
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(300 * time.Millisecond)
+		io.WriteString(w, "done")
+	})
+	srv := NewServer(handler, WithH2C(), WithSignalChannel(sigs), WithReady(ready))
+	errs := serveAsync(t, srv, listen(t), ready)
+
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+	client := &http.Client{
+		Transport: &http.Transport{Protocols: &protocols},
+		Timeout:   5 * time.Second,
+	}
+
+	type result struct {
+		resp *http.Response
+		body string
+		err  error
+	}
+	results := make(chan result, 1)
+	go func() {
+		resp, err := client.Get("http://" + srv.ListenAddr().String())
+		if err != nil {
+			results <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		results <- result{resp: resp, body: string(body), err: err}
+	}()
+
+	<-started
+	sigs <- syscall.SIGTERM
+
+	res := <-results
+	if res.err != nil {
+		t.Fatalf("in-flight h2c request failed: %v", res.err)
+	}
+	if res.resp.ProtoMajor != 2 {
+		t.Fatalf("response protocol = %s, want HTTP/2", res.resp.Proto)
+	}
+	if res.body != "done" {
+		t.Fatalf("response body = %q, want %q", res.body, "done")
+	}
+	if err := waitServe(t, errs); err != nil {
+		t.Fatalf("Serve() error = %v", err)
+	}
+}
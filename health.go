@@ -0,0 +1,88 @@
+package httpgrace
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type healthCheckConfig struct {
+	check     func() error
+	interval  time.Duration
+	threshold int
+}
+
+// WithSelfHealthCheck runs check every interval while the server is running,
+// and triggers a graceful shutdown once it fails failuresBeforeShutdown times
+// in a row, so that the process can be restarted by its supervisor.
+func WithSelfHealthCheck(check func() error, interval time.Duration, failuresBeforeShutdown int) Option {
+	return func(cfg *serverConfig) {
+		if check == nil || interval <= 0 {
+			return
+		}
+		cfg.healthCheck = &healthCheckConfig{
+			check:     check,
+			interval:  interval,
+			threshold: max(failuresBeforeShutdown, 1),
+		}
+	}
+}
+
+// monitorHealth runs the health check until the server stops or the
+// failure threshold is reached.
+func (s *Server) monitorHealth(done <-chan struct{}) {
+	hc := s.config.healthCheck
+
+	ticker := time.NewTicker(hc.interval)
+	defer ticker.Stop()
+
+	failures := 0
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		if s.shuttingDown.Load() {
+			return
+		}
+
+		err := hc.check()
+		if err == nil {
+			failures = 0
+			continue
+		}
+
+		failures++
+		s.log().Warn("health check failed",
+			"error", err,
+			"failures", failures,
+			"threshold", hc.threshold)
+
+		if failures >= hc.threshold {
+			s.log().Error("health check failure threshold reached, shutting down",
+				"failures", failures)
+			s.triggerShutdown(SignalHealthCheck)
+			return
+		}
+	}
+}
+
+// ReadinessHandler returns a handler for readiness probes, e.g. /readyz.
+// It responds 200 OK while the server is ready, and 503 Service Unavailable
+// with a Retry-After header once the shutdown has been triggered or the
+// server has been marked not ready with SetReady.
+func (s *Server) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.Ready() {
+			retryAfter := int(math.Ceil(s.config.shutdownTimeout.Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(max(retryAfter, 1)))
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, "ok")
+	})
+}
@@ -0,0 +1,92 @@
+package httpgrace
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+const hijackedPollInterval = 50 * time.Millisecond
+
+// WithWebSocketDrain gives hijacked connections registered with
+// Server.TrackHijacked up to d to close after the server has shut down,
+// e.g. to let RegisterOnShutdown callbacks send WebSocket close frames.
+// Connections still open after d are force-closed.
+func WithWebSocketDrain(d time.Duration) Option {
+	return func(cfg *serverConfig) {
+		cfg.websocketDrain = d
+	}
+}
+
+// TrackHijacked registers a connection obtained via http.Hijacker so that
+// graceful shutdown can wait for it and force-close it if needed. The returned
+// net.Conn must be used in place of c, so that closing it unregisters it.
+func (s *Server) TrackHijacked(c net.Conn) net.Conn {
+	tc := &trackedConn{Conn: c, srv: s}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.hijacked == nil {
+		s.hijacked = make(map[*trackedConn]struct{})
+	}
+	s.hijacked[tc] = struct{}{}
+
+	return tc
+}
+
+type trackedConn struct {
+	net.Conn
+	srv  *Server
+	once sync.Once
+}
+
+func (c *trackedConn) Close() error {
+	c.once.Do(func() {
+		c.srv.mu.Lock()
+		delete(c.srv.hijacked, c)
+		c.srv.mu.Unlock()
+	})
+	return c.Conn.Close()
+}
+
+func (s *Server) hijackedCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.hijacked)
+}
+
+// drainHijacked waits up to d for the tracked hijacked connections to close,
+// then force-closes the remaining ones.
+func (s *Server) drainHijacked(d time.Duration) {
+	if s.hijackedCount() == 0 {
+		return
+	}
+
+	s.log().Info("waiting for hijacked connections to close",
+		"connections", s.hijackedCount(),
+		"timeout", d)
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	ticker := time.NewTicker(hijackedPollInterval)
+	defer ticker.Stop()
+
+	for s.hijackedCount() > 0 {
+		select {
+		case <-ticker.C:
+		case <-timer.C:
+			s.mu.Lock()
+			remaining := make([]*trackedConn, 0, len(s.hijacked))
+			for c := range s.hijacked {
+				remaining = append(remaining, c)
+			}
+			s.mu.Unlock()
+
+			s.log().Warn("force-closing hijacked connections", "connections", len(remaining))
+			for _, c := range remaining {
+				c.Close()
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package httpgrace
+
+import (
+	"context"
+	"errors"
+)
+
+// WithPreDrainHook registers a function to run as soon as shutdown is
+// triggered, before the server stops accepting connections and starts
+// draining them, e.g. to deregister from service discovery.
+// Hooks run in registration order and all share one context, done when the
+// drain phase set with WithDrainTimeout expires, or after the shutdown
+// timeout when no drain timeout is set; a signal with the Immediate action
+// ends it as well. Errors are logged and do not prevent the remaining hooks
+// or the shutdown from running.
+func WithPreDrainHook(fn func(ctx context.Context) error) Option {
+	return func(cfg *serverConfig) {
+		if fn != nil {
+			cfg.preDrainHooks = append(cfg.preDrainHooks, fn)
+		}
+	}
+}
+
+func (s *Server) runPreDrainHooks(ctx context.Context) {
+	for i, hook := range s.config.preDrainHooks {
+		if err := hook(ctx); err != nil {
+			s.log().Error("pre-drain hook failed", "hook", i, "error", err)
+		}
+	}
+}
+
+// WithPreShutdownHook registers a function to run right before the server
+// is shut down, after the pre-drain hooks and the WithBeforeShutdown function.
+// Hooks run in registration order and receive the shutdown context. A failing
+// hook is logged and does not prevent the remaining ones from running, and its
+// error is joined to the error returned by the server.
+func WithPreShutdownHook(fn func(ctx context.Context) error) Option {
+	return func(cfg *serverConfig) {
+		if fn != nil {
+			cfg.preShutdownHooks = append(cfg.preShutdownHooks, fn)
+		}
+	}
+}
+
+func (s *Server) runPreShutdownHooks(ctx context.Context) error {
+	var errs []error
+	for i, hook := range s.config.preShutdownHooks {
+		if err := hook(ctx); err != nil {
+			s.log().Error("pre-shutdown hook failed", "hook", i, "error", err)
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// WithPostShutdownHook registers a function to run once the shutdown attempt
+// has completed, whether gracefully or not, e.g. to close database pools or
+// flush loggers. Post-shutdown hooks always run last, after the pre-drain and
+// pre-shutdown hooks and after the connections have been drained. They run
+// in registration order, and their errors are logged and joined to the error
+// returned by the server.
+func WithPostShutdownHook(fn func() error) Option {
+	return func(cfg *serverConfig) {
+		if fn != nil {
+			cfg.postShutdownHooks = append(cfg.postShutdownHooks, fn)
+		}
+	}
+}
+
+func (s *Server) runPostShutdownHooks() error {
+	var errs []error
+	for i, hook := range s.config.postShutdownHooks {
+		if err := hook(); err != nil {
+			s.log().Error("post-shutdown hook failed", "hook", i, "error", err)
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,72 @@
+package httpgrace
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// WithAllowedHosts rejects with 400 Bad Request the requests whose Host header
+// does not match one of hosts, to protect against Host header attacks and DNS
+// rebinding. Hosts are matched without the port, either exactly or with a
+// leading wildcard such as "*.example.com", matching any subdomain.
+// Requests to the health check paths (see WithHostCheckExemptPaths) are never
+// rejected.
+func WithAllowedHosts(hosts ...string) Option {
+	return func(cfg *serverConfig) {
+		for _, h := range hosts {
+			cfg.allowedHosts = append(cfg.allowedHosts, normalizeHost(h))
+		}
+	}
+}
+
+// WithHostCheckExemptPaths sets the paths that bypass the WithAllowedHosts
+// check (default: /healthz, /readyz, /livez).
+func WithHostCheckExemptPaths(paths ...string) Option {
+	return func(cfg *serverConfig) {
+		cfg.hostCheckExemptPaths = paths
+	}
+}
+
+// checkHost rejects the requests with a Host header that is not allowed.
+func (s *Server) checkHost(next http.Handler) http.Handler {
+	exempt := make(map[string]bool, len(s.config.hostCheckExemptPaths))
+	for _, p := range s.config.hostCheckExemptPaths {
+		exempt[p] = true
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !exempt[r.URL.Path] && !hostAllowed(s.config.allowedHosts, r.Host) {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func hostAllowed(allowed []string, host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = normalizeHost(host)
+
+	for _, pattern := range allowed {
+		if suffix, ok := strings.CutPrefix(pattern, "*"); ok {
+			if strings.HasSuffix(host, suffix) && len(host) > len(suffix) {
+				return true
+			}
+			continue
+		}
+		if host == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeHost lowercases a host and strips the IPv6 brackets and the
+// trailing dot of fully qualified names.
+func normalizeHost(host string) string {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	return strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+}
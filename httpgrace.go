@@ -2,12 +2,18 @@ package httpgrace
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
-	"syscall"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -15,11 +21,56 @@ import (
 type Option func(*serverConfig)
 
 type serverConfig struct {
-	shutdownTimeout time.Duration
-	logger          *slog.Logger
-	signals         []os.Signal
-	beforeShutdown  func()
-	serverOptions   []ServerOption
+	shutdownTimeout             time.Duration
+	forceCloseOnTimeout         bool
+	logger                      *slog.Logger
+	lifecycleLogger             *slog.Logger
+	logLevels                   map[LogEvent]slog.Level
+	signals                     []os.Signal
+	signalActions               map[os.Signal]Action
+	signalChannel               <-chan os.Signal
+	restartSignal               os.Signal
+	shutdownNotify              func(os.Signal)
+	ctx                         context.Context
+	beforeShutdown              func()
+	preDrainHooks               []func(ctx context.Context) error
+	preShutdownHooks            []func(ctx context.Context) error
+	postShutdownHooks           []func() error
+	serverOptions               []ServerOption
+	rateLimiter                 *ipRateLimiter
+	websocketDrain              time.Duration
+	admin                       *adminConfig
+	healthCheck                 *healthCheckConfig
+	drainPolicy                 DrainPolicy
+	disableKeepAlives           bool
+	disableKeepAlivesOnShutdown bool
+	drainDelay                  time.Duration
+	drainTimeout                time.Duration
+	forceKillTimeout            time.Duration
+	recovery                    bool
+	h2c                         bool
+	maxUptime                   time.Duration
+	tlsConfig                   *tls.Config
+	baseCtx                     context.Context
+	connContext                 func(ctx context.Context, c net.Conn) context.Context
+	cancelOnDrain               bool
+	socketMode                  os.FileMode
+	extraListeners              []net.Listener
+	proxyProtocol               bool
+	maxConnections              int
+	selfSigned                  bool
+	selfSignedHosts             []string
+	autocert                    CertManager
+	autocertChallengeAddr       string
+	ready                       chan<- struct{}
+	readyOnce                   *sync.Once
+	metrics                     MetricsRecorder
+
+	allowedHosts         []string
+	hostCheckExemptPaths []string
+
+	gracePeriodEnv      string
+	gracePeriodHeadroom time.Duration
 }
 
 // ServerOption configures the underlying http.Server
@@ -29,12 +80,46 @@ func defaultConfig() serverConfig {
 	return serverConfig{
 		shutdownTimeout: 10 * time.Second,
 		logger:          slog.Default(),
-		signals:         []os.Signal{syscall.SIGINT, syscall.SIGTERM},
+		signals:         defaultSignals(),
 		beforeShutdown:  func() {}, // Default no-op hook
+		metrics:         noopMetrics{},
+		socketMode:      0o660,
+
+		hostCheckExemptPaths: []string{"/healthz", "/readyz", "/livez"},
 	}
 }
 
-// WithTimeout sets graceful shutdown timeout duration.
+// newConfig applies the options on top of the defaults and validates the result.
+func newConfig(opts ...Option) serverConfig {
+	cfg := defaultConfig()
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	if cfg.gracePeriodEnv != "" {
+		cfg.applyGracePeriod()
+	}
+
+	if cfg.shutdownTimeout < 0 {
+		cfg.log().Warn("negative shutdown timeout, using zero",
+			"shutdown_timeout", cfg.shutdownTimeout)
+		cfg.shutdownTimeout = 0
+	}
+
+	return cfg
+}
+
+// log returns the logger used for the server lifecycle events.
+func (cfg *serverConfig) log() *slog.Logger {
+	if cfg.lifecycleLogger != nil {
+		return cfg.lifecycleLogger
+	}
+	return cfg.logger
+}
+
+// WithTimeout sets graceful shutdown timeout duration, i.e. the budget of the
+// connection-drain phase (see WithConnectionTimeout).
+// Negative values are treated as zero.
 func WithTimeout(d time.Duration) Option {
 	return func(cfg *serverConfig) {
 		cfg.shutdownTimeout = d
@@ -50,6 +135,16 @@ func WithLogger(l *slog.Logger) Option {
 	}
 }
 
+// WithJSONLifecycleLogs makes the server emit its own lifecycle logs
+// (startup, shutdown) as JSON to w, regardless of the logger set with WithLogger.
+func WithJSONLifecycleLogs(w io.Writer) Option {
+	return func(cfg *serverConfig) {
+		if w != nil {
+			cfg.lifecycleLogger = slog.New(slog.NewJSONHandler(w, nil))
+		}
+	}
+}
+
 // WithSignals sets which OS signals trigger graceful shutdown.
 func WithSignals(signals ...os.Signal) Option {
 	return func(cfg *serverConfig) {
@@ -59,6 +154,31 @@ func WithSignals(signals ...os.Signal) Option {
 	}
 }
 
+// WithoutSignals disables the OS signal handling, so that the shutdown can
+// only be triggered programmatically, e.g. with WithContext.
+func WithoutSignals() Option {
+	return func(cfg *serverConfig) {
+		cfg.signals = nil
+	}
+}
+
+// WithContext triggers the graceful shutdown when ctx is done, in addition
+// to the configured signals. Whichever comes first starts the shutdown.
+func WithContext(ctx context.Context) Option {
+	return func(cfg *serverConfig) {
+		cfg.ctx = ctx
+	}
+}
+
+// WithForceCloseOnTimeout forcibly closes the remaining connections when the
+// graceful shutdown times out, instead of leaving them open. The returned
+// error then wraps ErrForceClosed.
+func WithForceCloseOnTimeout(force bool) Option {
+	return func(cfg *serverConfig) {
+		cfg.forceCloseOnTimeout = force
+	}
+}
+
 func WithBeforeShutdown(fn func()) Option {
 	return func(cfg *serverConfig) {
 		if fn != nil {
@@ -67,6 +187,29 @@ func WithBeforeShutdown(fn func()) Option {
 	}
 }
 
+// WithReady sets a channel that is closed once the server is listening,
+// after which Server.ListenAddr returns the bound address. The same option
+// can be given to several servers, e.g. of a Group, to be notified when the
+// first of them is listening; the channel must not be passed to WithReady
+// more than once though, since each call closes it.
+func WithReady(ready chan<- struct{}) Option {
+	once := new(sync.Once)
+	return func(cfg *serverConfig) {
+		cfg.ready = ready
+		cfg.readyOnce = once
+	}
+}
+
+// WithExtraListener makes the server serve on ln as well, in addition to the
+// listener of the serving method, sharing the same graceful shutdown.
+func WithExtraListener(ln net.Listener) Option {
+	return func(cfg *serverConfig) {
+		if ln != nil {
+			cfg.extraListeners = append(cfg.extraListeners, ln)
+		}
+	}
+}
+
 // WithServerOptions allows configuring the underlying http.Server.
 func WithServerOptions(opts ...ServerOption) Option {
 	return func(cfg *serverConfig) {
@@ -75,6 +218,10 @@ func WithServerOptions(opts ...ServerOption) Option {
 }
 
 // Server option helpers
+func WithAddr(addr string) ServerOption {
+	return func(srv *http.Server) { srv.Addr = addr }
+}
+
 func WithReadTimeout(d time.Duration) ServerOption {
 	return func(srv *http.Server) { srv.ReadTimeout = d }
 }
@@ -89,183 +236,522 @@ func WithIdleTimeout(d time.Duration) ServerOption {
 
 // ListenAndServe starts a non-TLS HTTP server with graceful shutdown.
 func ListenAndServe(addr string, handler http.Handler, opts ...Option) error {
-	return listenAndServeInternal(addr, "", "", handler, opts...)
+	return listenAndServeInternal(addr, false, "", "", handler, opts...)
 }
 
 // ListenAndServeTLS starts a TLS HTTP server with graceful shutdown.
 func ListenAndServeTLS(addr, certFile, keyFile string, handler http.Handler, opts ...Option) error {
-	return listenAndServeInternal(addr, certFile, keyFile, handler, opts...)
+	return listenAndServeInternal(addr, true, certFile, keyFile, handler, opts...)
 }
 
 // Serve starts a non-TLS HTTP server with graceful shutdown on a custom net.Listener.
 func Serve(ln net.Listener, handler http.Handler, opts ...Option) error {
-	return serveInternal(ln, "", "", handler, opts...)
+	return serveInternal(ln, false, "", "", handler, opts...)
 }
 
 // ServeTLS starts a TLS HTTP server with graceful shutdown on a custom net.Listener.
 func ServeTLS(ln net.Listener, certFile, keyFile string, handler http.Handler, opts ...Option) error {
-	return serveInternal(ln, certFile, keyFile, handler, opts...)
+	return serveInternal(ln, true, certFile, keyFile, handler, opts...)
 }
 
 // Server wraps http.Server with built-in graceful shutdown capabilities.
 type Server struct {
 	*http.Server
 	config serverConfig
+
+	mu             sync.Mutex
+	startedAt      time.Time
+	addr           net.Addr
+	hijacked       map[*trackedConn]struct{}
+	sidecars       []*Server
+	trigger        chan os.Signal
+	stopped        chan struct{}
+	stopOnce       sync.Once
+	shutdownErr    error
+	errs           chan error
+	cancelRequests context.CancelFunc
+	background     WaitGroup
+
+	shuttingDown       atomic.Bool
+	notReady           atomic.Bool
+	keepAlivesDisabled atomic.Bool
+	inFlight           atomic.Int64
+	started            atomic.Bool
+	serving            atomic.Bool
+	immediate          atomic.Bool
+	activeConns        atomic.Int64
 }
 
 // NewServer creates a new Server with graceful shutdown capabilities.
 func NewServer(handler http.Handler, opts ...Option) *Server {
-	cfg := defaultConfig()
-	for _, opt := range opts {
-		opt(&cfg)
+	cfg := newConfig(opts...)
+
+	s := &Server{
+		Server:  &http.Server{},
+		config:  cfg,
+		trigger: make(chan os.Signal, 1),
+		stopped: make(chan struct{}),
+		errs:    make(chan error, 1),
 	}
-
-	srv := &http.Server{
-		Handler: handler,
+	s.Server.Handler = s.wrapHandler(handler)
+	if cfg.h2c {
+		s.enableH2C()
+	}
+	if cfg.tlsConfig != nil {
+		s.Server.TLSConfig = cfg.tlsConfig.Clone()
 	}
 
 	// Apply server options
 	for _, opt := range cfg.serverOptions {
-		opt(srv)
+		opt(s.Server)
 	}
-
-	return &Server{
-		Server: srv,
-		config: cfg,
+	s.setupContexts()
+	s.trackConnState()
+	if cfg.disableKeepAlives {
+		s.SetKeepAlivesEnabled(false)
 	}
+
+	return s
 }
 
 // ListenAndServe starts the server with graceful shutdown on the given address.
 func (s *Server) ListenAndServe(addr string) error {
-	return s.serveWithAddr(addr, "", "")
+	return s.serveWithAddr(addr, false, "", "")
 }
 
 // ListenAndServeTLS starts the TLS server with graceful shutdown.
 func (s *Server) ListenAndServeTLS(addr, certFile, keyFile string) error {
-	return s.serveWithAddr(addr, certFile, keyFile)
+	return s.serveWithAddr(addr, true, certFile, keyFile)
 }
 
 // Serve starts the server on the given listener.
 func (s *Server) Serve(ln net.Listener) error {
-	return s.serve(ln, "", "")
+	return s.serve(ln, false, "", "")
 }
 
 // ServeTLS starts the TLS server on the given listener.
 func (s *Server) ServeTLS(ln net.Listener, certFile, keyFile string) error {
-	return s.serve(ln, certFile, keyFile)
+	return s.serve(ln, true, certFile, keyFile)
+}
+
+// log returns the logger used for the server lifecycle events.
+func (s *Server) log() *slog.Logger {
+	return s.config.log()
+}
+
+// StartedAt returns the time the server started accepting connections,
+// or the zero time if it has not started yet.
+func (s *Server) StartedAt() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.startedAt
+}
+
+// Uptime returns how long the server has been accepting connections,
+// or zero if it has not started yet.
+func (s *Server) Uptime() time.Duration {
+	startedAt := s.StartedAt()
+	if startedAt.IsZero() {
+		return 0
+	}
+	return time.Since(startedAt)
+}
+
+// ListenAddr returns the address the server is listening on, or nil if it has
+// not started yet. It is useful to discover the port chosen by the OS when
+// listening on ":0". The Addr field of the embedded http.Server holds the
+// address the server was asked to listen on instead.
+func (s *Server) ListenAddr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addr
+}
+
+// SetReady marks the server as ready or not ready to receive traffic,
+// e.g. to temporarily pull it out of rotation without shutting it down.
+// A server that is shutting down is never ready, regardless of this setting.
+func (s *Server) SetReady(ready bool) {
+	s.notReady.Store(!ready)
+}
+
+// Ready reports whether the server is ready to receive traffic.
+func (s *Server) Ready() bool {
+	return !s.notReady.Load() && !s.shuttingDown.Load()
+}
+
+// SetKeepAlivesEnabled controls whether HTTP keep-alives are enabled,
+// like http.Server.SetKeepAlivesEnabled, and records the state so that it
+// can be observed with KeepAlivesDisabled.
+func (s *Server) SetKeepAlivesEnabled(v bool) {
+	s.Server.SetKeepAlivesEnabled(v)
+	s.keepAlivesDisabled.Store(!v)
+
+	if !v && s.shuttingDown.Load() {
+		s.log().Info("keep-alives disabled")
+	}
+}
+
+// KeepAlivesDisabled reports whether HTTP keep-alives have been disabled.
+func (s *Server) KeepAlivesDisabled() bool {
+	return s.keepAlivesDisabled.Load()
+}
+
+// ServeAll starts the server on all the given listeners, sharing the same
+// graceful shutdown. It returns once all of them have stopped, with their
+// joined errors. If a listener fails, the other ones are shut down gracefully.
+func (s *Server) ServeAll(listeners ...net.Listener) error {
+	return s.serveListeners(listeners, false, "", "")
+}
+
+// Shutdown triggers the same graceful shutdown performed on signal, and
+// waits for it to complete or for ctx to be done. It returns the shutdown
+// error, which is also returned by the serving method.
+// It shadows http.Server.Shutdown, that would bypass the shutdown sequence.
+func (s *Server) Shutdown(ctx context.Context) error {
+	if !s.serving.Load() {
+		// not serving yet: make a later Serve return immediately, there is
+		// no shutdown sequence to wait for
+		s.shuttingDown.Store(true)
+		return s.Server.Shutdown(ctx)
+	}
+
+	s.triggerShutdown(SignalStop)
+
+	select {
+	case <-s.stopped:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.shutdownErr
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stop gracefully shuts down the server, waiting for the shutdown to complete.
+func (s *Server) Stop() error {
+	return s.Shutdown(context.Background())
 }
 
 // serveWithAddr creates a listener and serves on it
-func (s *Server) serveWithAddr(addr, certFile, keyFile string) error {
+func (s *Server) serveWithAddr(addr string, useTLS bool, certFile, keyFile string) error {
 	s.Server.Addr = addr
 
-	ln, err := net.Listen("tcp", addr)
+	ln, err := s.listen("tcp", addr)
 	if err != nil {
 		return err
 	}
 	defer ln.Close()
 
-	return s.serve(ln, certFile, keyFile)
+	return s.serve(ln, useTLS, certFile, keyFile)
 }
 
-func (s *Server) serve(ln net.Listener, certFile, keyFile string) error {
+func (s *Server) serve(ln net.Listener, useTLS bool, certFile, keyFile string) error {
+	return s.serveListeners([]net.Listener{ln}, useTLS, certFile, keyFile)
+}
+
+// serveListeners serves on the given listeners, and the extra ones set with
+// WithExtraListener, until all of them stop.
+func (s *Server) serveListeners(lns []net.Listener, useTLS bool, certFile, keyFile string) error {
 	quit := make(chan error)
+	// release the Shutdown callers even if serving fails without shutting down
+	defer s.markStopped()
+	// from now on a Shutdown goes through the shutdown sequence
+	s.serving.Store(true)
+
+	lns = append(slices.Clip(lns), s.config.extraListeners...)
+	if len(lns) == 0 {
+		return errNoListeners
+	}
+	// the listeners handed over on a graceful restart
+	restartLns := slices.Clone(lns)
+
+	if s.config.maxConnections > 0 {
+		limiter := newConnLimiter(s, s.config.maxConnections)
+		for i, ln := range lns {
+			lns[i] = &limitListener{Listener: ln, limiter: limiter, done: make(chan struct{})}
+		}
+	}
+	if s.config.proxyProtocol {
+		for i, ln := range lns {
+			lns[i] = &proxyListener{Listener: ln, srv: s}
+		}
+	}
+
+	useTLS, certFile, keyFile, err := s.setupTLS(useTLS, certFile, keyFile)
+	if err != nil {
+		return err
+	}
+
+	sidecarLns, err := s.startSidecars()
+	if err != nil {
+		return err
+	}
+	defer s.closeSidecars()
+	restartLns = append(restartLns, sidecarLns...)
+
+	sigChan := s.config.signalChannel
+	if sigChan == nil {
+		notified := make(chan os.Signal, 1)
+		if signals := s.config.notifySignals(); len(signals) > 0 {
+			signal.Notify(notified, signals...)
+			defer signal.Stop(notified)
+		}
+		sigChan = notified
+	}
 
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, s.config.signals...)
-	defer signal.Stop(sigChan)
+	if s.config.ctx != nil {
+		stop := context.AfterFunc(s.config.ctx, func() {
+			s.triggerShutdown(SignalContextDone)
+		})
+		defer stop()
+	}
+
+	// done stops the shutdown handler when serving ends without a shutdown
+	done := make(chan struct{})
+	defer close(done)
 
 	// Start shutdown handler
-	go s.handleShutdown(sigChan, quit)
+	// began is closed once the shutdown handler starts shutting down
+	began := make(chan struct{})
+	go s.handleShutdown(sigChan, done, began, quit)
+
+	if s.config.healthCheck != nil {
+		go s.monitorHealth(done)
+	}
 
 	// Log server start
 	mode := "HTTP"
-	if certFile != "" && keyFile != "" {
+	if useTLS {
 		mode = "HTTPS"
 	}
-	s.config.logger.Info("starting server",
-		"mode", mode,
-		"addr", ln.Addr().String(),
-		"shutdown_timeout", s.config.shutdownTimeout)
+	for _, ln := range lns {
+		s.logEvent(LogStart, "starting server",
+			"mode", mode,
+			"addr", ln.Addr().String(),
+			"shutdown_timeout", s.config.shutdownTimeout)
+		s.config.metrics.ServerStarted(ln.Addr().String())
+	}
+
+	s.mu.Lock()
+	s.startedAt = time.Now()
+	s.addr = lns[0].Addr()
+	s.mu.Unlock()
+
+	if s.config.ready != nil {
+		s.config.readyOnce.Do(func() { close(s.config.ready) })
+	}
+
+	if s.config.restartSignal != nil {
+		notifyRestartReady()
+		s.watchRestart(restartLns, done)
+	}
+
+	if s.config.maxUptime > 0 {
+		timer := s.startMaxUptimeTimer()
+		defer timer.Stop()
+	}
 
 	// Start server
-	var err error
-	if certFile != "" && keyFile != "" {
-		err = s.Server.ServeTLS(ln, certFile, keyFile)
-	} else {
-		err = s.Server.Serve(ln)
+	serveErrs := make(chan error, len(lns))
+	for _, ln := range lns {
+		go func() {
+			if useTLS {
+				serveErrs <- s.Server.ServeTLS(ln, certFile, keyFile)
+			} else {
+				serveErrs <- s.Server.Serve(ln)
+			}
+		}()
 	}
 
-	// Handle server errors
-	if err != nil && err != http.ErrServerClosed {
-		s.config.logger.Error("server error", "error", err)
-		return err
+	// Handle server errors: if a listener fails while others are still
+	// serving, shut them down gracefully
+	var errs []error
+	shuttingDown := false
+	for remaining := len(lns); remaining > 0; remaining-- {
+		err := <-serveErrs
+		if err == nil || s.isShutdownErr(err) {
+			shuttingDown = true
+			continue
+		}
+
+		s.log().Error("server error", "error", err)
+		errs = append(errs, &ServeError{Phase: PhaseServe, Err: err})
+		if remaining > 1 && !shuttingDown {
+			s.triggerShutdown(SignalListenerFailed)
+			shuttingDown = true
+		}
+	}
+
+	// Wait for graceful shutdown to complete and return any shutdown error.
+	// The server may also have been closed without the shutdown sequence,
+	// e.g. with http.Server.Close, in which case there is nothing to wait for.
+	if shuttingDown {
+		select {
+		case <-began:
+			errs = append(errs, <-quit)
+		default:
+		}
 	}
+	return errors.Join(errs...)
+}
+
+// markStopped releases the callers waiting for the server to stop.
+func (s *Server) markStopped() {
+	s.stopOnce.Do(func() { close(s.stopped) })
+}
 
-	// Wait for graceful shutdown to complete and return any shutdown error
-	shutdownErr := <-quit
-	return shutdownErr
+// isShutdownErr reports whether err is the expected result of a shutdown.
+// Wrapping listeners may surface net.ErrClosed once Shutdown closed them,
+// so it is treated as clean as well if the server is shutting down.
+func (s *Server) isShutdownErr(err error) bool {
+	if errors.Is(err, http.ErrServerClosed) {
+		return true
+	}
+	return errors.Is(err, net.ErrClosed) && s.shuttingDown.Load()
 }
 
-func (s *Server) handleShutdown(sigChan <-chan os.Signal, quit chan<- error) {
+func (s *Server) handleShutdown(sigChan <-chan os.Signal, done <-chan struct{}, began chan<- struct{}, quit chan<- error) {
 	defer close(quit)
 
-	sig := <-sigChan
-	s.config.logger.Info("shutdown signal received", "signal", sig.String())
+	var sig os.Signal
+	select {
+	case sig = <-sigChan:
+	case sig = <-s.trigger:
+	case <-done:
+		// serving failed on its own
+		return
+	}
+	close(began)
+	s.shuttingDown.Store(true)
+	signalReceived := time.Now()
+	s.logEvent(LogSignal, "shutdown signal received", "signal", sig.String())
+	s.config.metrics.ShutdownStarted(sig.String())
+	if s.config.shutdownNotify != nil {
+		s.config.shutdownNotify(sig)
+	}
+	if s.config.disableKeepAlivesOnShutdown {
+		s.SetKeepAlivesEnabled(false)
+	}
+
+	// abortCtx is cancelled by a signal with the Immediate action
+	abortCtx, abort := context.WithCancel(context.Background())
+	defer abort()
+
+	interrupt, stopWatching := s.watchSignals(sigChan, abort)
+	defer stopWatching()
+
+	if s.config.signalAction(sig) == Immediate {
+		s.abortShutdown(sig, abort)
+	}
+
+	// The drain phase covers the pre-drain hooks and the drain delay
+	drainCtx, cancelDrain := phaseContext(abortCtx, s.config.drainTimeout)
+	defer cancelDrain()
+
+	if abortCtx.Err() == nil {
+		// Without a drain timeout, the hooks are bounded by the connection timeout
+		hooksCtx, cancelHooks := drainCtx, context.CancelFunc(func() {})
+		if s.config.drainTimeout <= 0 {
+			hooksCtx, cancelHooks = context.WithTimeout(drainCtx, s.config.shutdownTimeout)
+		}
+		s.runPreDrainHooks(hooksCtx)
+		cancelHooks()
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), s.config.shutdownTimeout)
+	if s.config.drainDelay > 0 && abortCtx.Err() == nil {
+		s.waitDrainDelay(drainCtx, interrupt)
+	}
+	s.checkPhaseBudget(drainCtx, "drain", s.config.drainTimeout)
+
+	ctx, cancel := context.WithTimeout(abortCtx, s.config.shutdownTimeout)
 	defer cancel()
 
-	s.config.beforeShutdown()
+	var hooksErr error
+	if abortCtx.Err() == nil {
+		s.config.beforeShutdown()
+		hooksErr = s.runPreShutdownHooks(ctx)
+	}
 
 	shutdownStart := time.Now()
-	err := s.Server.Shutdown(ctx)
-	if err != nil {
-		s.config.logger.Error(
+	s.cancelRequestContexts()
+	stopReporting := s.reportActiveConnections()
+	err := s.shutdownServers(ctx)
+	stopReporting()
+	s.checkPhaseBudget(ctx, "connection", s.config.shutdownTimeout)
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = fmt.Errorf("%w: %w", ErrShutdownTimeout, err)
+	}
+	if s.immediate.Load() {
+		err = ErrImmediateShutdown
+	} else if errors.Is(err, context.DeadlineExceeded) && s.config.forceCloseOnTimeout {
+		s.log().Warn(
+			"server shutdown timed out, forcing close",
+			"timeout", s.config.shutdownTimeout,
+			"duration", time.Since(shutdownStart),
+		)
+		s.closeServers()
+		err = fmt.Errorf("%w: %w", ErrForceClosed, err)
+	} else if err != nil {
+		s.logEvent(LogFailed,
 			"server shutdown failed",
 			"error", err,
 			"timeout", s.config.shutdownTimeout,
 			"duration", time.Since(shutdownStart),
 		)
 	} else {
-		s.config.logger.Info(
+		s.logEvent(LogCompleted,
 			"server shutdown completed gracefully",
 			"duration", time.Since(shutdownStart),
 		)
 	}
-	quit <- err
-}
 
-// Internal implementation for backwards compatibility
-func listenAndServeInternal(addr, certFile, keyFile string, handler http.Handler, opts ...Option) error {
-	ln, err := net.Listen("tcp", addr)
-	if err != nil {
-		return err
+	// The force-kill phase waits for hijacked connections and background
+	// goroutines, sharing the deadline of the connection phase by default
+	killCtx := ctx
+	if s.config.forceKillTimeout > 0 {
+		var cancelKill context.CancelFunc
+		killCtx, cancelKill = context.WithTimeout(abortCtx, s.config.forceKillTimeout)
+		defer cancelKill()
 	}
-	defer ln.Close()
 
-	return serveInternal(ln, certFile, keyFile, handler, opts...)
-}
-
-func serveInternal(ln net.Listener, certFile, keyFile string, handler http.Handler, opts ...Option) error {
-	cfg := defaultConfig()
-	for _, opt := range opts {
-		opt(&cfg)
+	if s.immediate.Load() {
+		s.drainHijacked(0)
+	} else if s.config.websocketDrain > 0 {
+		d := s.config.websocketDrain
+		if s.config.forceKillTimeout > 0 {
+			d = min(d, s.config.forceKillTimeout)
+		}
+		s.drainHijacked(d)
 	}
 
-	srv := &http.Server{
-		Handler: handler,
+	s.waitBackground(killCtx)
+	if s.config.forceKillTimeout > 0 {
+		s.checkPhaseBudget(killCtx, "force-kill", s.config.forceKillTimeout)
 	}
 
-	// Apply server options
-	for _, opt := range cfg.serverOptions {
-		opt(srv)
-	}
+	cleanupErr := s.runPostShutdownHooks()
 
-	server := &Server{
-		Server: srv,
-		config: cfg,
+	err = errors.Join(hooksErr, err, cleanupErr)
+	if err != nil {
+		err = &ServeError{Phase: PhaseShutdown, Err: err}
 	}
+	s.mu.Lock()
+	s.shutdownErr = err
+	s.mu.Unlock()
+	s.markStopped()
+	s.config.metrics.ShutdownCompleted(time.Since(signalReceived), err)
+
+	select {
+	case quit <- err:
+	case <-done:
+	}
+}
+
+// Internal implementation for backwards compatibility
+func listenAndServeInternal(addr string, useTLS bool, certFile, keyFile string, handler http.Handler, opts ...Option) error {
+	return NewServer(handler, opts...).serveWithAddr(addr, useTLS, certFile, keyFile)
+}
 
-	return server.serve(ln, certFile, keyFile)
+func serveInternal(ln net.Listener, useTLS bool, certFile, keyFile string, handler http.Handler, opts ...Option) error {
+	return NewServer(handler, opts...).serve(ln, useTLS, certFile, keyFile)
 }
@@ -0,0 +1,208 @@
+package httpgrace
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// serveAsync serves srv on ln in the background, returning the channel
+// receiving the error of Serve, once the server is ready.
+func serveAsync(t *testing.T, srv *Server, ln net.Listener, ready <-chan struct{}) <-chan error {
+	t.Helper()
+
+	errs := make(chan error, 1)
+	go func() { errs <- srv.Serve(ln) }()
+
+	select {
+	case <-ready:
+	case err := <-errs:
+		t.Fatalf("server stopped before being ready: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("server not ready")
+	}
+	return errs
+}
+
+func waitServe(t *testing.T, errs <-chan error) error {
+	t.Helper()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return")
+		return nil
+	}
+}
+
+// waitGoroutines waits for the number of goroutines to go back to at most n.
+func waitGoroutines(t *testing.T, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > n {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<16)
+			t.Fatalf("goroutines leaked: %d, want at most %d\n%s",
+				runtime.NumGoroutine(), n, buf[:runtime.Stack(buf, true)])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func listen(t *testing.T) net.Listener {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ln
+}
+
+func TestServeDoesNotLeakGoroutines(t *testing.T) {
+	tests := []struct {
+		name    string
+		stop    func(srv *Server, ln net.Listener)
+		wantErr bool
+	}{
+		{
+			name:    "listener closed",
+			stop:    func(_ *Server, ln net.Listener) { ln.Close() },
+			wantErr: true,
+		},
+		{
+			name: "http.Server.Close",
+			stop: func(srv *Server, _ net.Listener) { srv.Close() },
+		},
+		{
+			name: "http.Server.Shutdown",
+			stop: func(srv *Server, _ net.Listener) { srv.Server.Shutdown(t.Context()) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := runtime.NumGoroutine()
+
+			ready := make(chan struct{})
+			ln := listen(t)
+			srv := NewServer(http.NotFoundHandler(), WithoutSignals(), WithReady(ready))
+			errs := serveAsync(t, srv, ln, ready)
+
+			tt.stop(srv, ln)
+			err := waitServe(t, errs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Serve() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err := srv.Stop(); err != nil {
+				t.Fatalf("Stop() after Serve returned: %v", err)
+			}
+			waitGoroutines(t, before)
+		})
+	}
+}
+
+// wrappingListener wraps the Accept errors of its listener, as listeners
+// adding TLS or metrics do.
+type wrappingListener struct {
+	net.Listener
+}
+
+func (l *wrappingListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, fmt.Errorf("wrapping listener: %w", err)
+	}
+	return c, nil
+}
+
+func TestServeWrappedErrClosed(t *testing.T) {
+	t.Run("closed while shutting down", func(t *testing.T) {
+		ready := make(chan struct{})
+		sigs := make(chan os.Signal, 1)
+		ln := &wrappingListener{Listener: listen(t)}
+		srv := NewServer(http.NotFoundHandler(),
+			WithSignalChannel(sigs),
+			WithReady(ready),
+			WithDrainDelay(200*time.Millisecond))
+		errs := serveAsync(t, srv, ln, ready)
+
+		sigs <- syscall.SIGTERM
+		for !srv.shuttingDown.Load() {
+			time.Sleep(time.Millisecond)
+		}
+		// the listener closes during the drain delay, before http.Server.Shutdown
+		ln.Close()
+
+		if err := waitServe(t, errs); err != nil {
+			t.Fatalf("Serve() error = %v, want nil", err)
+		}
+	})
+
+	t.Run("closed without shutdown", func(t *testing.T) {
+		ready := make(chan struct{})
+		ln := &wrappingListener{Listener: listen(t)}
+		srv := NewServer(http.NotFoundHandler(), WithoutSignals(), WithReady(ready))
+		errs := serveAsync(t, srv, ln, ready)
+
+		ln.Close()
+
+		err := waitServe(t, errs)
+		if !errors.Is(err, net.ErrClosed) {
+			t.Fatalf("Serve() error = %v, want net.ErrClosed", err)
+		}
+		var serveErr *ServeError
+		if !errors.As(err, &serveErr) || serveErr.Phase != PhaseServe {
+			t.Fatalf("Serve() error = %v, want a ServeError of the serve phase", err)
+		}
+	})
+}
+
+func TestNegativeTimeoutClampedToZero(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+	cfg := newConfig(WithLogger(logger), WithTimeout(-time.Second))
+
+	if cfg.shutdownTimeout != 0 {
+		t.Fatalf("shutdownTimeout = %v, want 0", cfg.shutdownTimeout)
+	}
+	if !strings.Contains(logs.String(), "level=WARN msg=\"negative shutdown timeout, using zero\"") {
+		t.Fatalf("missing warning, got logs: %q", logs.String())
+	}
+}
+
+func TestWithReadySharedByServers(t *testing.T) {
+	ready := make(chan struct{})
+	opt := WithReady(ready)
+
+	first := NewServer(http.NotFoundHandler(), WithoutSignals(), opt)
+	second := NewServer(http.NotFoundHandler(), WithoutSignals(), opt)
+	errs := serveAsync(t, first, listen(t), ready)
+	// the second server must not close the ready channel again
+	secondErrs := make(chan error, 1)
+	go func() { secondErrs <- second.Serve(listen(t)) }()
+	for second.ListenAddr() == nil {
+		time.Sleep(time.Millisecond)
+	}
+
+	first.Stop()
+	second.Stop()
+	for _, errs := range []<-chan error{errs, secondErrs} {
+		if err := waitServe(t, errs); err != nil {
+			t.Fatalf("Serve() error = %v", err)
+		}
+	}
+}
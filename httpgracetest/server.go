@@ -0,0 +1,97 @@
+// Package httpgracetest provides a test server running the real graceful
+// shutdown of httpgrace, like net/http/httptest does for net/http.
+package httpgracetest
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"syscall"
+
+	"github.com/enrichman/httpgrace"
+)
+
+// Server is an httpgrace server listening on an ephemeral loopback port,
+// driven by signals sent with Signal instead of the process signals.
+type Server struct {
+	// URL is the base URL of the server, e.g. http://127.0.0.1:1234.
+	URL string
+	// Client is an HTTP client for the server, whose idle connections are
+	// closed by Close.
+	Client *http.Client
+	// Server is the underlying httpgrace server.
+	Server *httpgrace.Server
+
+	signals  chan os.Signal
+	signaled sync.Once
+	done     chan struct{}
+	err      error
+}
+
+// NewServer starts a non-TLS server serving handler with the given options,
+// returning once it is ready. WithSignalChannel and WithReady options are
+// overridden by the ones of the test server. It panics if the server cannot
+// start, as httptest.NewServer does.
+func NewServer(handler http.Handler, opts ...httpgrace.Option) *Server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("httpgracetest: failed to listen: %v", err))
+	}
+
+	ts := &Server{
+		signals: make(chan os.Signal, 1),
+		done:    make(chan struct{}),
+	}
+
+	ready := make(chan struct{})
+	opts = append(opts,
+		httpgrace.WithSignalChannel(ts.signals),
+		httpgrace.WithReady(ready),
+	)
+	ts.Server = httpgrace.NewServer(handler, opts...)
+
+	go func() {
+		defer close(ts.done)
+		ts.err = ts.Server.Serve(ln)
+	}()
+
+	select {
+	case <-ready:
+	case <-ts.done:
+		panic(fmt.Sprintf("httpgracetest: failed to serve: %v", ts.err))
+	}
+
+	ts.URL = "http://" + ts.Server.ListenAddr().String()
+	ts.Client = &http.Client{Transport: &http.Transport{}}
+	return ts
+}
+
+// Signal sends sig to the server as if the process received it, e.g. to
+// trigger the shutdown or to test the actions set with WithSignalAction.
+func (ts *Server) Signal(sig os.Signal) {
+	ts.signaled.Do(func() {})
+	select {
+	case ts.signals <- sig:
+	case <-ts.done:
+	}
+}
+
+// Close shuts the server down gracefully with SIGTERM, unless a signal was
+// already sent with Signal, and waits for the shutdown to complete. It returns
+// the error returned by the serving method.
+func (ts *Server) Close() error {
+	ts.signaled.Do(func() {
+		select {
+		case ts.signals <- syscall.SIGTERM:
+		case <-ts.done:
+		}
+	})
+	<-ts.done
+
+	if t, ok := ts.Client.Transport.(*http.Transport); ok {
+		t.CloseIdleConnections()
+	}
+	return ts.err
+}
@@ -0,0 +1,61 @@
+package httpgracetest
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/enrichman/httpgrace"
+)
+
+// testSignal is a signal the process cannot receive.
+type testSignal string
+
+func (s testSignal) String() string { return string(s) }
+func (testSignal) Signal()          {}
+
+func TestServer(t *testing.T) {
+	ts := NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "hello")
+	}))
+
+	resp, err := ts.Client.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "hello" {
+		t.Fatalf("body = %q, want %q", body, "hello")
+	}
+
+	if err := ts.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+}
+
+func TestServerSignal(t *testing.T) {
+	const custom = testSignal("custom")
+
+	notified := make(chan os.Signal, 1)
+	ts := NewServer(http.NotFoundHandler(),
+		httpgrace.WithShutdownNotify(func(sig os.Signal) { notified <- sig }),
+		// a SIGTERM sent by Close would abort the shutdown still in progress
+		httpgrace.WithSignalAction(syscall.SIGTERM, httpgrace.Immediate),
+		httpgrace.WithDrainDelay(200*time.Millisecond))
+
+	ts.Signal(custom)
+	if sig := <-notified; sig != custom {
+		t.Fatalf("shutdown triggered by %v, want %v", sig, custom)
+	}
+
+	if err := ts.Close(); err != nil {
+		t.Fatalf("Close() error = %v, want nil: a second signal was sent", err)
+	}
+}
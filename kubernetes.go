@@ -0,0 +1,67 @@
+package httpgrace
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// WithKubernetesGracePeriod derives the shutdown timeout from the pod
+// termination grace period stored in the envKey environment variable, minus
+// headroom, so that the drain completes before the kubelet sends SIGKILL.
+//
+// The variable can hold either a number of seconds (as in
+// terminationGracePeriodSeconds) or a Go duration such as "45s". If it is
+// unset or invalid the shutdown timeout is left untouched. When valid, it takes
+// precedence over WithTimeout.
+func WithKubernetesGracePeriod(envKey string, headroom time.Duration) Option {
+	return func(cfg *serverConfig) {
+		cfg.gracePeriodEnv = envKey
+		cfg.gracePeriodHeadroom = headroom
+	}
+}
+
+// applyGracePeriod sets the shutdown timeout from the grace period env variable.
+func (cfg *serverConfig) applyGracePeriod() {
+	value, ok := os.LookupEnv(cfg.gracePeriodEnv)
+	if !ok {
+		cfg.log().Warn("grace period env variable not set, keeping shutdown timeout",
+			"env", cfg.gracePeriodEnv,
+			"shutdown_timeout", cfg.shutdownTimeout)
+		return
+	}
+
+	gracePeriod, err := parseGracePeriod(value)
+	if err != nil {
+		cfg.log().Warn("invalid grace period, keeping shutdown timeout",
+			"env", cfg.gracePeriodEnv,
+			"value", value,
+			"error", err,
+			"shutdown_timeout", cfg.shutdownTimeout)
+		return
+	}
+
+	timeout := gracePeriod - cfg.gracePeriodHeadroom
+	if timeout <= 0 {
+		cfg.log().Warn("grace period does not exceed headroom, keeping shutdown timeout",
+			"env", cfg.gracePeriodEnv,
+			"grace_period", gracePeriod,
+			"headroom", cfg.gracePeriodHeadroom,
+			"shutdown_timeout", cfg.shutdownTimeout)
+		return
+	}
+
+	cfg.shutdownTimeout = timeout
+	cfg.log().Info("shutdown timeout derived from grace period",
+		"env", cfg.gracePeriodEnv,
+		"grace_period", gracePeriod,
+		"headroom", cfg.gracePeriodHeadroom,
+		"shutdown_timeout", timeout)
+}
+
+func parseGracePeriod(value string) (time.Duration, error) {
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return time.ParseDuration(value)
+}
@@ -0,0 +1,96 @@
+package httpgrace
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// WithMaxConnections limits the number of concurrent connections of the
+// server to n, across all its listeners. Once the limit is reached, new
+// connections wait in the listen backlog until others close. A warning is
+// logged each time the server saturates.
+func WithMaxConnections(n int) Option {
+	return func(cfg *serverConfig) {
+		cfg.maxConnections = n
+	}
+}
+
+// connLimiter is the semaphore shared by the limited listeners of a server.
+type connLimiter struct {
+	srv       *Server
+	slots     chan struct{}
+	saturated atomic.Bool
+}
+
+func newConnLimiter(s *Server, n int) *connLimiter {
+	return &connLimiter{srv: s, slots: make(chan struct{}, n)}
+}
+
+// acquire takes a slot, waiting for one to be released if there are none,
+// and reports false if done was closed meanwhile.
+func (l *connLimiter) acquire(done <-chan struct{}) bool {
+	select {
+	case l.slots <- struct{}{}:
+		l.saturated.Store(false)
+		return true
+	default:
+	}
+
+	if l.saturated.CompareAndSwap(false, true) {
+		l.srv.log().Warn("connection limit reached, waiting for connections to close",
+			"max_connections", cap(l.slots))
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+func (l *connLimiter) release() {
+	<-l.slots
+}
+
+// limitListener is a listener accepting connections only when the limiter
+// has a free slot. Closing it unblocks a pending Accept, so that the limit
+// does not hold up the shutdown.
+type limitListener struct {
+	net.Listener
+	limiter   *connLimiter
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (l *limitListener) Accept() (net.Conn, error) {
+	if !l.limiter.acquire(l.done) {
+		return nil, &net.OpError{Op: "accept", Net: l.Addr().Network(), Addr: l.Addr(), Err: net.ErrClosed}
+	}
+
+	c, err := l.Listener.Accept()
+	if err != nil {
+		l.limiter.release()
+		return nil, err
+	}
+	return &limitConn{Conn: c, release: l.limiter.release}, nil
+}
+
+func (l *limitListener) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return l.Listener.Close()
+}
+
+// limitConn releases its slot when closed.
+type limitConn struct {
+	net.Conn
+	release     func()
+	releaseOnce sync.Once
+}
+
+func (c *limitConn) Close() error {
+	err := c.Conn.Close()
+	c.releaseOnce.Do(c.release)
+	return err
+}
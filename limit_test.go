@@ -0,0 +1,94 @@
+package httpgrace
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// dialRequest opens a connection to srv and sends a request on it.
+func dialRequest(t *testing.T, srv *Server) (net.Conn, *bufio.Reader) {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", srv.ListenAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	fmt.Fprintf(conn, "GET / HTTP/1.1\r\nHost: test\r\n\r\n")
+	return conn, bufio.NewReader(conn)
+}
+
+func readResponse(t *testing.T, r *bufio.Reader) {
+	t.Helper()
+
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+}
+
+func TestMaxConnectionsDoesNotBlockShutdown(t *testing.T) {
+	const timeout = 2 * time.Second
+
+	ready := make(chan struct{})
+	sigs := make(chan os.Signal, 1)
+	srv := NewServer(http.NotFoundHandler(),
+		WithSignalChannel(sigs),
+		WithReady(ready),
+		WithTimeout(timeout),
+		WithMaxConnections(1))
+	errs := serveAsync(t, srv, listen(t), ready)
+
+	// the first connection takes the only slot, the second one waits in
+	// the backlog
+	_, r := dialRequest(t, srv)
+	readResponse(t, r)
+	dialRequest(t, srv)
+
+	start := time.Now()
+	sigs <- syscall.SIGTERM
+
+	if err := waitServe(t, errs); err != nil {
+		t.Fatalf("Serve() error = %v, want nil", err)
+	}
+	if elapsed := time.Since(start); elapsed >= timeout {
+		t.Fatalf("Serve returned after %v, the limiter held up the shutdown", elapsed)
+	}
+}
+
+func TestMaxConnectionsReleasesSlots(t *testing.T) {
+	ready := make(chan struct{})
+	srv := NewServer(http.NotFoundHandler(),
+		WithoutSignals(),
+		WithReady(ready),
+		WithMaxConnections(1))
+	errs := serveAsync(t, srv, listen(t), ready)
+
+	first, r := dialRequest(t, srv)
+	readResponse(t, r)
+
+	second, r := dialRequest(t, srv)
+	second.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	if _, err := r.Peek(1); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("second connection served over the limit, error = %v", err)
+	}
+
+	first.Close()
+	second.SetReadDeadline(time.Now().Add(5 * time.Second))
+	readResponse(t, r)
+
+	if err := srv.Stop(); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if err := waitServe(t, errs); err != nil {
+		t.Fatalf("Serve() error = %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package httpgrace
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// systemd passes the sockets starting from this file descriptor.
+const systemdListenFdsStart = 3
+
+// ListenAndServeURL starts a non-TLS HTTP server with graceful shutdown on the
+// listener described by target. Supported targets are:
+//
+//	tcp://host:port    (also tcp4:// and tcp6://, or a bare host:port)
+//	unix:///path/to/socket
+//	abstract://name    (Linux abstract Unix socket)
+//	fd://3             (an already open listening file descriptor)
+//	systemd://[name]   (socket activation, the first socket or the one named name)
+func ListenAndServeURL(target string, handler http.Handler, opts ...Option) error {
+	return NewServer(handler, opts...).ListenAndServeURL(target)
+}
+
+// ListenAndServeURL starts the server with graceful shutdown on the listener
+// described by target. See the package level ListenAndServeURL for the
+// supported targets.
+func (s *Server) ListenAndServeURL(target string) error {
+	ln, err := s.listenURL(target)
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+
+	return s.serve(ln, false, "", "")
+}
+
+// listenURL creates the listener described by target.
+func (s *Server) listenURL(target string) (net.Listener, error) {
+	if !strings.Contains(target, "://") {
+		return s.listen("tcp", target)
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid listen target %q: %w", target, err)
+	}
+
+	switch u.Scheme {
+	case "tcp", "tcp4", "tcp6":
+		return s.listen(u.Scheme, u.Host)
+	case "unix":
+		return s.listenUnix(u.Host + u.Path)
+	case "abstract":
+		return net.Listen("unix", "@"+strings.TrimPrefix(u.Host+u.Path, "/"))
+	case "fd":
+		fd, err := strconv.Atoi(u.Host)
+		if err != nil || fd < 0 {
+			return nil, fmt.Errorf("invalid file descriptor in listen target %q", target)
+		}
+		return fileListener(uintptr(fd), target)
+	case "systemd":
+		return systemdListener(u.Host)
+	default:
+		return nil, fmt.Errorf("unsupported listen target scheme %q", u.Scheme)
+	}
+}
+
+// fileListener creates a listener from an open file descriptor.
+func fileListener(fd uintptr, name string) (net.Listener, error) {
+	f := os.NewFile(fd, name)
+	if f == nil {
+		return nil, fmt.Errorf("invalid file descriptor %d", fd)
+	}
+	// net.FileListener duplicates the descriptor
+	defer f.Close()
+
+	return net.FileListener(f)
+}
+
+// systemdListener returns the socket passed by systemd socket activation,
+// either the first one or the one matching name in LISTEN_FDNAMES.
+func systemdListener(name string) (net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, fmt.Errorf("no sockets passed by systemd")
+	}
+
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count < 1 {
+		return nil, fmt.Errorf("no sockets passed by systemd")
+	}
+
+	index := 0
+	if name != "" {
+		names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+		index = -1
+		for i, n := range names {
+			if n == name && i < count {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return nil, fmt.Errorf("no socket named %q passed by systemd", name)
+		}
+	}
+
+	fd := systemdListenFdsStart + index
+	return fileListener(uintptr(fd), "systemd:"+name)
+}
@@ -0,0 +1,47 @@
+package httpgrace
+
+import (
+	"context"
+	"log/slog"
+)
+
+// LogEvent identifies a lifecycle log line whose level can be changed with
+// WithLogLevel.
+type LogEvent int
+
+const (
+	// LogStart is the "starting server" line, logged at Info by default.
+	LogStart LogEvent = iota
+	// LogSignal is the "shutdown signal received" line, logged at Info by default.
+	LogSignal
+	// LogCompleted is the "server shutdown completed gracefully" line, logged
+	// at Info by default.
+	LogCompleted
+	// LogFailed is the "server shutdown failed" line, e.g. on a shutdown
+	// timeout, logged at Error by default.
+	LogFailed
+)
+
+// WithLogLevel sets the level of the given lifecycle log line. A level below
+// the one enabled in the logger suppresses it, e.g. slog.LevelDebug for
+// LogStart hides the "starting server" line of an Info logger.
+func WithLogLevel(event LogEvent, level slog.Level) Option {
+	return func(cfg *serverConfig) {
+		if cfg.logLevels == nil {
+			cfg.logLevels = make(map[LogEvent]slog.Level)
+		}
+		cfg.logLevels[event] = level
+	}
+}
+
+// logEvent logs msg at the level configured for event.
+func (s *Server) logEvent(event LogEvent, msg string, args ...any) {
+	level, ok := s.config.logLevels[event]
+	if !ok {
+		level = slog.LevelInfo
+		if event == LogFailed {
+			level = slog.LevelError
+		}
+	}
+	s.log().Log(context.Background(), level, msg, args...)
+}
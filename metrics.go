@@ -0,0 +1,33 @@
+package httpgrace
+
+import "time"
+
+// MetricsRecorder receives the lifecycle events of the server, e.g. to feed
+// shutdown durations into Prometheus or OpenTelemetry. Its methods are called
+// alongside the matching log lines and must not block.
+type MetricsRecorder interface {
+	// ServerStarted is called when the server starts serving on addr, once
+	// per listener.
+	ServerStarted(addr string)
+	// ShutdownStarted is called when the shutdown signal is received.
+	ShutdownStarted(signal string)
+	// ShutdownCompleted is called once the shutdown is over, with its duration
+	// since the signal and the error returned by the serving method, if any.
+	ShutdownCompleted(d time.Duration, err error)
+}
+
+// WithMetrics reports the lifecycle events of the server to m.
+func WithMetrics(m MetricsRecorder) Option {
+	return func(cfg *serverConfig) {
+		if m != nil {
+			cfg.metrics = m
+		}
+	}
+}
+
+// noopMetrics is the default MetricsRecorder, discarding all events.
+type noopMetrics struct{}
+
+func (noopMetrics) ServerStarted(string)                   {}
+func (noopMetrics) ShutdownStarted(string)                 {}
+func (noopMetrics) ShutdownCompleted(time.Duration, error) {}
@@ -0,0 +1,37 @@
+package httpgrace
+
+import "net/http"
+
+// wrapHandler installs the built-in middlewares around the user handler.
+func (s *Server) wrapHandler(handler http.Handler) http.Handler {
+	if s.config.rateLimiter != nil {
+		handler = s.config.rateLimiter.middleware(handler)
+	}
+	if len(s.config.allowedHosts) > 0 {
+		handler = s.checkHost(handler)
+	}
+	if s.config.drainPolicy != ServeNormally {
+		handler = s.applyDrainPolicy(handler)
+	}
+	if s.config.recovery {
+		handler = Recoverer(s.config.logger)(handler)
+	}
+	return s.trackInFlight(handler)
+}
+
+// trackInFlight counts the requests currently being handled.
+func (s *Server) trackInFlight(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.inFlight.Add(1)
+		defer s.inFlight.Add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// InFlight returns the number of requests currently being handled.
+// It only counts requests passing through the handler installed by the
+// package, so requests are not counted if a ServerOption replaces the
+// http.Server Handler.
+func (s *Server) InFlight() int64 {
+	return s.inFlight.Load()
+}
@@ -0,0 +1,52 @@
+package httpgrace
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// WithDrainTimeout bounds the drain phase of the shutdown, made of the
+// pre-drain hooks and the drain delay. When unset, the pre-drain hooks are
+// bounded by the connection timeout and the drain delay always runs in full.
+func WithDrainTimeout(d time.Duration) Option {
+	return func(cfg *serverConfig) {
+		cfg.drainTimeout = d
+	}
+}
+
+// WithConnectionTimeout bounds the connection-drain phase of the shutdown, in
+// which the pre-shutdown hooks run and the active connections are waited for.
+// WithTimeout is an alias of it.
+func WithConnectionTimeout(d time.Duration) Option {
+	return WithTimeout(d)
+}
+
+// WithForceKillTimeout bounds the phase that follows the connection drain, in
+// which hijacked connections (see WithWebSocketDrain) and the goroutines of
+// Server.WaitGroup are waited for. When unset, that phase shares the deadline
+// of the connection-drain phase.
+func WithForceKillTimeout(d time.Duration) Option {
+	return func(cfg *serverConfig) {
+		cfg.forceKillTimeout = d
+	}
+}
+
+// phaseContext returns the context of a shutdown phase, with a timeout of d
+// if it is positive.
+func phaseContext(parent context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d > 0 {
+		return context.WithTimeout(parent, d)
+	}
+	return context.WithCancel(parent)
+}
+
+// checkPhaseBudget logs whether the shutdown phase run with ctx exceeded its
+// timeout.
+func (s *Server) checkPhaseBudget(ctx context.Context, phase string, timeout time.Duration) {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		s.log().Warn("shutdown phase exceeded its budget",
+			"phase", phase,
+			"timeout", timeout)
+	}
+}
@@ -0,0 +1,239 @@
+package httpgrace
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// proxyHeaderTimeout bounds the time a client has to send the PROXY header.
+	proxyHeaderTimeout = 10 * time.Second
+	// proxyV1MaxLength is the maximum length of a v1 header, CRLF included.
+	proxyV1MaxLength = 107
+)
+
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// WithProxyProtocol expects every connection to start with a PROXY protocol
+// header, v1 (text) or v2 (binary), as sent by HAProxy or an AWS NLB, and
+// reports the client address it carries as the RemoteAddr of the connection.
+// Connections without a valid header are closed.
+//
+// The header is read on the first Read, RemoteAddr or LocalAddr call on the
+// connection, waiting up to 10 seconds for the client to send it. The
+// http.Server calls the ConnContext and ConnState(StateNew) hooks from its
+// accept loop, so hooks set with WithConnContext or a ServerOption must not
+// call RemoteAddr or LocalAddr: a slow client would hold up the acceptance of
+// every other connection. Use the address of the request in the handlers
+// instead.
+func WithProxyProtocol() Option {
+	return func(cfg *serverConfig) {
+		cfg.proxyProtocol = true
+	}
+}
+
+// proxyListener wraps a listener to parse the PROXY header of its connections.
+// The header is parsed lazily, on the first use of the connection, so that a
+// slow client does not block Accept. The http.Server first uses it from the
+// goroutine serving the connection, unless a ConnContext or ConnState hook
+// asks for its address.
+type proxyListener struct {
+	net.Listener
+	srv *Server
+}
+
+func (l *proxyListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &proxyConn{Conn: c, srv: l.srv}, nil
+}
+
+type proxyConn struct {
+	net.Conn
+	srv *Server
+
+	once   sync.Once
+	r      *bufio.Reader
+	remote net.Addr
+	local  net.Addr
+	err    error
+}
+
+func (c *proxyConn) Read(b []byte) (int, error) {
+	c.once.Do(c.readHeader)
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.r.Read(b)
+}
+
+func (c *proxyConn) RemoteAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.remote != nil {
+		return c.remote
+	}
+	return c.Conn.RemoteAddr()
+}
+
+func (c *proxyConn) LocalAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.local != nil {
+		return c.local
+	}
+	return c.Conn.LocalAddr()
+}
+
+// readHeader reads the PROXY header, closing the connection if it is invalid.
+func (c *proxyConn) readHeader() {
+	c.r = bufio.NewReader(c.Conn)
+
+	if err := c.Conn.SetReadDeadline(time.Now().Add(proxyHeaderTimeout)); err != nil {
+		c.err = err
+		return
+	}
+
+	c.remote, c.local, c.err = parseProxyHeader(c.r)
+	if c.err != nil {
+		c.srv.log().Warn("invalid PROXY protocol header, closing connection",
+			"remote_addr", c.Conn.RemoteAddr().String(),
+			"error", c.err)
+		c.Conn.Close()
+		return
+	}
+
+	c.err = c.Conn.SetReadDeadline(time.Time{})
+}
+
+// parseProxyHeader reads a v1 or v2 PROXY header, returning the source and
+// destination addresses. Both are nil if the header does not carry them,
+// e.g. for health checks of the balancer.
+func parseProxyHeader(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	// Both headers are longer than the v2 signature
+	sig, err := r.Peek(len(proxyV2Signature))
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading PROXY header: %w", err)
+	}
+
+	switch {
+	case bytes.Equal(sig, proxyV2Signature):
+		return parseProxyV2(r)
+	case bytes.HasPrefix(sig, []byte("PROXY ")):
+		return parseProxyV1(r)
+	default:
+		return nil, nil, errors.New("missing PROXY header")
+	}
+}
+
+// parseProxyV1 parses a header like "PROXY TCP4 1.2.3.4 5.6.7.8 1234 80\r\n".
+func parseProxyV1(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	var line []byte
+	for len(line) < proxyV1MaxLength {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading PROXY v1 header: %w", err)
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+
+	header, ok := strings.CutSuffix(string(line), "\r\n")
+	if !ok {
+		return nil, nil, errors.New("PROXY v1 header too long or not terminated by CRLF")
+	}
+
+	fields := strings.Split(header, " ")
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, nil, fmt.Errorf("malformed PROXY v1 header %q", header)
+	}
+
+	src, err := parseProxyV1Addr(fields[1], fields[2], fields[4])
+	if err != nil {
+		return nil, nil, err
+	}
+	dst, err := parseProxyV1Addr(fields[1], fields[3], fields[5])
+	if err != nil {
+		return nil, nil, err
+	}
+	return src, dst, nil
+}
+
+func parseProxyV1Addr(proto, host, port string) (*net.TCPAddr, error) {
+	ip := net.ParseIP(host)
+	if ip == nil || (proto == "TCP4") != (ip.To4() != nil) {
+		return nil, fmt.Errorf("invalid %s address %q in PROXY v1 header", proto, host)
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %q in PROXY v1 header", port)
+	}
+	return &net.TCPAddr{IP: ip, Port: int(p)}, nil
+}
+
+// parseProxyV2 parses a binary header, made of the signature, the version and
+// command, the address family and protocol, the length of the addresses, and
+// the addresses themselves.
+func parseProxyV2(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	var hdr [16]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, nil, fmt.Errorf("reading PROXY v2 header: %w", err)
+	}
+
+	if hdr[12]>>4 != 2 {
+		return nil, nil, fmt.Errorf("unsupported PROXY protocol version %d", hdr[12]>>4)
+	}
+	command := hdr[12] & 0x0f
+	family := hdr[13] >> 4
+
+	payload := make([]byte, binary.BigEndian.Uint16(hdr[14:16]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, nil, fmt.Errorf("reading PROXY v2 addresses: %w", err)
+	}
+
+	switch command {
+	case 0x0: // LOCAL, e.g. a health check of the balancer itself
+		return nil, nil, nil
+	case 0x1: // PROXY
+	default:
+		return nil, nil, fmt.Errorf("unsupported PROXY v2 command %d", command)
+	}
+
+	var ipLen int
+	switch family {
+	case 0x1: // AF_INET
+		ipLen = net.IPv4len
+	case 0x2: // AF_INET6
+		ipLen = net.IPv6len
+	default:
+		// AF_UNSPEC and AF_UNIX carry no IP, keep the addresses of the connection
+		return nil, nil, nil
+	}
+
+	if len(payload) < 2*ipLen+4 {
+		return nil, nil, errors.New("PROXY v2 addresses too short")
+	}
+	src := &net.TCPAddr{
+		IP:   net.IP(payload[:ipLen]),
+		Port: int(binary.BigEndian.Uint16(payload[2*ipLen:])),
+	}
+	dst := &net.TCPAddr{
+		IP:   net.IP(payload[ipLen : 2*ipLen]),
+		Port: int(binary.BigEndian.Uint16(payload[2*ipLen+2:])),
+	}
+	return src, dst, nil
+}
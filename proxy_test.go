@@ -0,0 +1,238 @@
+package httpgrace
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// proxyV2Header builds a v2 header with the given version and command,
+// address family and addresses block.
+func proxyV2Header(verCmd, family byte, addrs []byte) []byte {
+	var b bytes.Buffer
+	b.Write(proxyV2Signature)
+	b.WriteByte(verCmd)
+	b.WriteByte(family<<4 | 0x1) // STREAM
+	binary.Write(&b, binary.BigEndian, uint16(len(addrs)))
+	b.Write(addrs)
+	return b.Bytes()
+}
+
+// proxyV2Addrs builds the addresses block of a v2 header.
+func proxyV2Addrs(src, dst net.IP, srcPort, dstPort uint16) []byte {
+	var b bytes.Buffer
+	b.Write(src)
+	b.Write(dst)
+	binary.Write(&b, binary.BigEndian, srcPort)
+	binary.Write(&b, binary.BigEndian, dstPort)
+	return b.Bytes()
+}
+
+func TestParseProxyHeader(t *testing.T) {
+	ipv4Addrs := proxyV2Addrs(net.IPv4(1, 2, 3, 4).To4(), net.IPv4(5, 6, 7, 8).To4(), 1234, 80)
+	ipv6Addrs := proxyV2Addrs(net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2"), 1234, 443)
+
+	tests := []struct {
+		name     string
+		header   []byte
+		wantSrc  string
+		wantDst  string
+		wantErr  bool
+		wantRest string
+	}{
+		{
+			name:     "v1 TCP4",
+			header:   []byte("PROXY TCP4 1.2.3.4 5.6.7.8 1234 80\r\nGET"),
+			wantSrc:  "1.2.3.4:1234",
+			wantDst:  "5.6.7.8:80",
+			wantRest: "GET",
+		},
+		{
+			name:     "v1 TCP6",
+			header:   []byte("PROXY TCP6 2001:db8::1 2001:db8::2 1234 443\r\nGET"),
+			wantSrc:  "[2001:db8::1]:1234",
+			wantDst:  "[2001:db8::2]:443",
+			wantRest: "GET",
+		},
+		{
+			name:     "v1 UNKNOWN",
+			header:   []byte("PROXY UNKNOWN\r\nGET"),
+			wantRest: "GET",
+		},
+		{
+			name:    "v1 IPv6 address with TCP4",
+			header:  []byte("PROXY TCP4 2001:db8::1 5.6.7.8 1234 80\r\n"),
+			wantErr: true,
+		},
+		{
+			name:    "v1 unknown protocol",
+			header:  []byte("PROXY UDP4 1.2.3.4 5.6.7.8 1234 80\r\n"),
+			wantErr: true,
+		},
+		{
+			name:    "v1 header too long",
+			header:  []byte("PROXY TCP4 " + strings.Repeat("1", 120) + "\r\n"),
+			wantErr: true,
+		},
+		{
+			name:    "v1 missing CRLF",
+			header:  []byte("PROXY TCP4 1.2.3.4 5.6.7.8 1234 80\nGET / HTTP/1.1\r\n"),
+			wantErr: true,
+		},
+		{
+			name:    "v1 bad port",
+			header:  []byte("PROXY TCP4 1.2.3.4 5.6.7.8 70000 80\r\n"),
+			wantErr: true,
+		},
+		{
+			name:    "v1 missing fields",
+			header:  []byte("PROXY TCP4 1.2.3.4 5.6.7.8 1234\r\n"),
+			wantErr: true,
+		},
+		{
+			name:     "v2 PROXY over AF_INET",
+			header:   append(proxyV2Header(0x21, 0x1, ipv4Addrs), "GET"...),
+			wantSrc:  "1.2.3.4:1234",
+			wantDst:  "5.6.7.8:80",
+			wantRest: "GET",
+		},
+		{
+			name:     "v2 PROXY over AF_INET6",
+			header:   append(proxyV2Header(0x21, 0x2, ipv6Addrs), "GET"...),
+			wantSrc:  "[2001:db8::1]:1234",
+			wantDst:  "[2001:db8::2]:443",
+			wantRest: "GET",
+		},
+		{
+			name:     "v2 LOCAL",
+			header:   append(proxyV2Header(0x20, 0x1, ipv4Addrs), "GET"...),
+			wantRest: "GET",
+		},
+		{
+			name:     "v2 AF_UNSPEC",
+			header:   append(proxyV2Header(0x21, 0x0, nil), "GET"...),
+			wantRest: "GET",
+		},
+		{
+			name:    "v2 addresses shorter than the family",
+			header:  proxyV2Header(0x21, 0x2, ipv4Addrs),
+			wantErr: true,
+		},
+		{
+			name:    "v2 truncated addresses",
+			header:  proxyV2Header(0x21, 0x1, ipv4Addrs)[:20],
+			wantErr: true,
+		},
+		{
+			name:    "v2 bad version",
+			header:  proxyV2Header(0x11, 0x1, ipv4Addrs),
+			wantErr: true,
+		},
+		{
+			name:    "v2 bad command",
+			header:  proxyV2Header(0x22, 0x1, ipv4Addrs),
+			wantErr: true,
+		},
+		{
+			name:    "missing header",
+			header:  []byte("GET / HTTP/1.1\r\nHost: test\r\n\r\n"),
+			wantErr: true,
+		},
+		{
+			name:    "empty",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := bufio.NewReader(bytes.NewReader(tt.header))
+			src, dst, err := parseProxyHeader(r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseProxyHeader() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if got := addrString(src); got != tt.wantSrc {
+				t.Errorf("source = %q, want %q", got, tt.wantSrc)
+			}
+			if got := addrString(dst); got != tt.wantDst {
+				t.Errorf("destination = %q, want %q", got, tt.wantDst)
+			}
+			rest, _ := io.ReadAll(r)
+			if string(rest) != tt.wantRest {
+				t.Errorf("data after the header = %q, want %q", rest, tt.wantRest)
+			}
+		})
+	}
+}
+
+func addrString(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	return addr.String()
+}
+
+func TestProxyProtocolServe(t *testing.T) {
+	ready := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.RemoteAddr)
+	})
+	srv := NewServer(handler, WithoutSignals(), WithReady(ready), WithProxyProtocol())
+	errs := serveAsync(t, srv, listen(t), ready)
+
+	t.Run("valid header", func(t *testing.T) {
+		conn, err := net.Dial("tcp", srv.ListenAddr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		fmt.Fprint(conn, "PROXY TCP4 1.2.3.4 5.6.7.8 1234 80\r\n")
+		fmt.Fprint(conn, "GET / HTTP/1.1\r\nHost: test\r\n\r\n")
+		resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if string(body) != "1.2.3.4:1234" {
+			t.Fatalf("RemoteAddr = %q, want the address of the header", body)
+		}
+	})
+
+	t.Run("invalid header", func(t *testing.T) {
+		conn, err := net.Dial("tcp", srv.ListenAddr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		fmt.Fprint(conn, "GET / HTTP/1.1\r\nHost: test\r\n\r\n")
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, err := conn.Read(make([]byte, 1))
+		// EOF or a reset, depending on the data left unread by the server
+		if n != 0 || err == nil || errors.Is(err, os.ErrDeadlineExceeded) {
+			t.Fatalf("Read() = %d, %v, want the connection closed", n, err)
+		}
+	})
+
+	if err := srv.Stop(); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if err := waitServe(t, errs); err != nil {
+		t.Fatalf("Serve() error = %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package httpgrace
+
+import (
+	"hash/maphash"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	rateLimitShards        = 32
+	rateLimitSweepInterval = time.Minute
+)
+
+// WithRateLimitPerIP limits each client IP to rps requests per second, allowing
+// bursts of up to burst requests. Requests over the limit are rejected with
+// 429 Too Many Requests and a Retry-After header.
+func WithRateLimitPerIP(rps float64, burst int) Option {
+	return func(cfg *serverConfig) {
+		if rps <= 0 {
+			return
+		}
+		cfg.rateLimiter = newIPRateLimiter(rps, burst)
+	}
+}
+
+// ipRateLimiter is a token-bucket limiter keyed by client IP. Buckets are
+// spread across shards to reduce lock contention, and buckets that have fully
+// refilled are evicted, since they are indistinguishable from new ones.
+type ipRateLimiter struct {
+	rate   float64
+	burst  float64
+	seed   maphash.Seed
+	shards [rateLimitShards]rateLimitShard
+}
+
+type rateLimitShard struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newIPRateLimiter(rps float64, burst int) *ipRateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+
+	l := &ipRateLimiter{
+		rate:  rps,
+		burst: float64(burst),
+		seed:  maphash.MakeSeed(),
+	}
+	for i := range l.shards {
+		l.shards[i].buckets = make(map[string]*tokenBucket)
+	}
+	return l
+}
+
+// allow consumes a token for key, returning how long to wait when none is left.
+func (l *ipRateLimiter) allow(key string, now time.Time) (bool, time.Duration) {
+	shard := &l.shards[maphash.String(l.seed, key)%rateLimitShards]
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if now.Sub(shard.lastSweep) >= rateLimitSweepInterval {
+		l.sweep(shard, now)
+	}
+
+	b, ok := shard.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		shard.buckets[key] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	return false, wait
+}
+
+// sweep evicts buckets of the shard that have refilled completely.
+// The caller must hold the shard lock.
+func (l *ipRateLimiter) sweep(shard *rateLimitShard, now time.Time) {
+	refill := time.Duration(l.burst / l.rate * float64(time.Second))
+	for key, b := range shard.buckets {
+		if now.Sub(b.last) >= refill {
+			delete(shard.buckets, key)
+		}
+	}
+	shard.lastSweep = now
+}
+
+func (l *ipRateLimiter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ok, wait := l.allow(clientIP(r), time.Now())
+		if !ok {
+			retryAfter := int(math.Ceil(wait.Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(max(retryAfter, 1)))
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientIP returns the IP of the client that sent the request.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,48 @@
+package httpgrace
+
+import (
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+)
+
+// WithRecovery recovers from panics in the handler, logging them with the
+// configured logger and responding 500 Internal Server Error, see Recoverer.
+func WithRecovery() Option {
+	return func(cfg *serverConfig) {
+		cfg.recovery = true
+	}
+}
+
+// Recoverer returns a middleware recovering from panics in the wrapped
+// handler. The panic is logged with its stack trace and a 500 Internal Server
+// Error is returned. Panics with http.ErrAbortHandler are propagated, to
+// preserve their net/http semantics.
+func Recoverer(logger *slog.Logger) func(http.Handler) http.Handler {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+
+				logger.Error("panic recovered",
+					"panic", rec,
+					"method", r.Method,
+					"path", r.URL.Path,
+					"stack", string(debug.Stack()))
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package httpgrace
+
+import (
+	"net"
+	"os"
+	"time"
+)
+
+const (
+	// restartListenersEnv holds the number of listeners passed to the new
+	// process of a graceful restart, from file descriptor restartReadyFd+1 on.
+	restartListenersEnv = "HTTPGRACE_RESTART_LISTENERS"
+	// restartReadyFd is the pipe the new process writes to once serving.
+	restartReadyFd = 3
+	// restartReadyTimeout bounds the time the new process has to get ready.
+	restartReadyTimeout = time.Minute
+)
+
+// WithGracefulRestart re-executes the binary when sig is received, e.g.
+// SIGUSR2 to reload the configuration or upgrade the binary without downtime.
+// The listeners of the server are handed over to the new process, which
+// reuses them in place of listening on the same addresses. Once the new
+// process is serving, this one shuts down gracefully, reporting
+// SignalRestart; if the new process fails to start or to get ready, this one
+// keeps serving.
+//
+// The new process must configure the server with WithGracefulRestart as well.
+// Only the listeners created by the server from an address are reused, i.e.
+// the ones of ListenAndServe, ListenAndServeTLS, ListenAndServeUnix,
+// ListenAndServeURL with a tcp or unix target, and Start, as well as the ones
+// of the admin server and of the ACME challenge server.
+// Graceful restarts are only supported on Unix systems.
+func WithGracefulRestart(sig os.Signal) Option {
+	return func(cfg *serverConfig) {
+		cfg.restartSignal = sig
+	}
+}
+
+// listen creates a listener on addr, reusing the one handed over by the
+// parent process on a graceful restart, if any.
+func (s *Server) listen(network, addr string) (net.Listener, error) {
+	if ln := s.inheritedListener(network, addr); ln != nil {
+		return ln, nil
+	}
+	return net.Listen(network, addr)
+}
+
+// inheritedListener returns the listener on addr handed over by the parent
+// process on a graceful restart, or nil.
+func (s *Server) inheritedListener(network, addr string) net.Listener {
+	if s.config.restartSignal == nil {
+		return nil
+	}
+
+	ln := takeInheritedListener(network, addr)
+	if ln != nil {
+		s.log().Info("reusing listener of the previous process", "addr", ln.Addr().String())
+	}
+	return ln
+}
+
+// listenerMatches reports whether ln listens on the given address, with
+// unspecified IPs matching any of them. Addresses with port zero never match,
+// since they ask for a new port.
+func listenerMatches(ln net.Listener, network, addr string) bool {
+	switch got := ln.Addr().(type) {
+	case *net.TCPAddr:
+		want, err := net.ResolveTCPAddr(network, addr)
+		if err != nil || want.Port == 0 || want.Port != got.Port {
+			return false
+		}
+		if len(want.IP) == 0 || want.IP.IsUnspecified() {
+			return got.IP.IsUnspecified()
+		}
+		return want.IP.Equal(got.IP)
+	case *net.UnixAddr:
+		return network == "unix" && got.Name == addr
+	default:
+		return false
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !unix
+
+package httpgrace
+
+import "net"
+
+func takeInheritedListener(network, addr string) net.Listener {
+	return nil
+}
+
+func notifyRestartReady() {}
+
+// watchRestart only warns, since graceful restarts need Unix file descriptor
+// passing.
+func (s *Server) watchRestart(lns []net.Listener, done <-chan struct{}) {
+	s.log().Warn("graceful restart is not supported on this platform")
+}
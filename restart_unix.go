@@ -0,0 +1,171 @@
+//go:build unix
+
+package httpgrace
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// inherited holds what the parent process handed over on a graceful restart.
+var inherited struct {
+	once      sync.Once
+	mu        sync.Mutex
+	listeners []net.Listener
+	ready     *os.File
+}
+
+// loadInherited loads the listeners handed over by the parent process.
+func loadInherited() {
+	n, err := strconv.Atoi(os.Getenv(restartListenersEnv))
+	if err != nil || n < 1 {
+		return
+	}
+	os.Unsetenv(restartListenersEnv)
+
+	inherited.ready = os.NewFile(restartReadyFd, "restart-ready")
+	for i := range n {
+		ln, err := fileListener(uintptr(restartReadyFd+1+i), "restart-listener")
+		if err != nil {
+			continue
+		}
+		inherited.listeners = append(inherited.listeners, ln)
+	}
+}
+
+// takeInheritedListener returns the inherited listener on addr, if any, so
+// that it is used only once.
+func takeInheritedListener(network, addr string) net.Listener {
+	inherited.once.Do(loadInherited)
+
+	inherited.mu.Lock()
+	defer inherited.mu.Unlock()
+	for i, ln := range inherited.listeners {
+		if listenerMatches(ln, network, addr) {
+			inherited.listeners = slices.Delete(inherited.listeners, i, i+1)
+			return ln
+		}
+	}
+	return nil
+}
+
+// notifyRestartReady tells the parent process of a graceful restart, if any,
+// that this process is serving, so that it can shut down.
+func notifyRestartReady() {
+	inherited.once.Do(loadInherited)
+
+	inherited.mu.Lock()
+	defer inherited.mu.Unlock()
+	if inherited.ready != nil {
+		inherited.ready.Write([]byte{1})
+		inherited.ready.Close()
+		inherited.ready = nil
+	}
+}
+
+// watchRestart performs a graceful restart on the restart signal, handing lns
+// over to the new process, until done is closed.
+func (s *Server) watchRestart(lns []net.Listener, done <-chan struct{}) {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, s.config.restartSignal)
+
+	go func() {
+		defer signal.Stop(sigChan)
+
+		for {
+			var sig os.Signal
+			select {
+			case sig = <-sigChan:
+			case <-done:
+				return
+			}
+			if s.shuttingDown.Load() {
+				return
+			}
+
+			s.log().Info("graceful restart requested", "signal", sig.String())
+			pid, err := s.restart(lns)
+			if err != nil {
+				s.log().Error("graceful restart failed, still serving", "error", err)
+				continue
+			}
+
+			s.log().Info("new process ready, shutting down", "pid", pid)
+			s.triggerShutdown(SignalRestart)
+			return
+		}
+	}()
+}
+
+// restart starts a new process of the same binary with the listeners, and
+// waits for it to be serving, returning its pid.
+func (s *Server) restart(lns []net.Listener) (int, error) {
+	files := make([]*os.File, 0, len(lns))
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	for _, ln := range lns {
+		fl, ok := ln.(interface{ File() (*os.File, error) })
+		if !ok {
+			return 0, fmt.Errorf("listener %s cannot be handed over", ln.Addr())
+		}
+		f, err := fl.File()
+		if err != nil {
+			return 0, fmt.Errorf("handing over listener %s: %w", ln.Addr(), err)
+		}
+		files = append(files, f)
+	}
+
+	ready, readyW, err := os.Pipe()
+	if err != nil {
+		return 0, err
+	}
+	defer ready.Close()
+
+	cmd := exec.Command(os.Args[0], os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.Env = append(restartEnv(), restartListenersEnv+"="+strconv.Itoa(len(files)))
+	cmd.ExtraFiles = append([]*os.File{readyW}, files...)
+
+	err = cmd.Start()
+	readyW.Close()
+	if err != nil {
+		return 0, fmt.Errorf("starting new process: %w", err)
+	}
+
+	// the pipe is closed without a write if the new process exits early
+	ready.SetReadDeadline(time.Now().Add(restartReadyTimeout))
+	if _, err := ready.Read(make([]byte, 1)); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return 0, fmt.Errorf("new process did not get ready: %w", err)
+	}
+	go cmd.Wait()
+
+	// the socket files are now used by the new process
+	for _, ln := range lns {
+		if ul, ok := ln.(*net.UnixListener); ok {
+			ul.SetUnlinkOnClose(false)
+		}
+	}
+
+	return cmd.Process.Pid, nil
+}
+
+// restartEnv returns the environment of the new process, without the
+// variables of a previous graceful restart.
+func restartEnv() []string {
+	return slices.DeleteFunc(os.Environ(), func(kv string) bool {
+		return strings.HasPrefix(kv, restartListenersEnv+"=")
+	})
+}
@@ -0,0 +1,106 @@
+package httpgrace
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"slices"
+	"time"
+)
+
+const (
+	selfSignedCommonName = "httpgrace self-signed development certificate"
+	selfSignedValidity   = 24 * time.Hour
+)
+
+var errSelfSignedWithFiles = errors.New("httpgrace: WithSelfSignedCert cannot be used with certificate files")
+
+// WithSelfSignedCert serves TLS with an in-memory self-signed certificate,
+// generated at startup and valid for 24 hours, covering localhost and the
+// given hostnames or IPs. It is meant for local development and tests only,
+// and must never be used in production. It cannot be combined with
+// certificate files.
+func WithSelfSignedCert(hosts ...string) Option {
+	return func(cfg *serverConfig) {
+		cfg.selfSigned = true
+		cfg.selfSignedHosts = hosts
+	}
+}
+
+// installSelfSignedCert generates the self-signed certificate and adds it to
+// the TLS configuration of the underlying http.Server.
+func (s *Server) installSelfSignedCert() error {
+	cert, err := generateSelfSignedCert(s.config.selfSignedHosts)
+	if err != nil {
+		return err
+	}
+
+	// the config may be shared with the caller, through WithServerOptions
+	config := &tls.Config{}
+	if s.Server.TLSConfig != nil {
+		config = s.Server.TLSConfig.Clone()
+	}
+	config.Certificates = append(slices.Clip(config.Certificates), cert)
+	s.Server.TLSConfig = config
+
+	s.log().Warn("serving with a self-signed development certificate, NEVER use it in production",
+		"hosts", cert.Leaf.DNSNames,
+		"ips", cert.Leaf.IPAddresses,
+		"expires", cert.Leaf.NotAfter)
+
+	return nil
+}
+
+func generateSelfSignedCert(hosts []string) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: selfSignedCommonName},
+		NotBefore:             now.Add(-time.Minute),
+		NotAfter:              now.Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else if h != "" {
+			template.DNSNames = append(template.DNSNames, h)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}, nil
+}
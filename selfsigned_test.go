@@ -0,0 +1,35 @@
+package httpgrace
+
+import (
+	"crypto/tls"
+	"net/http"
+	"testing"
+)
+
+func TestSelfSignedCertKeepsConfig(t *testing.T) {
+	configCert, err := generateSelfSignedCert(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certs := make([]tls.Certificate, 1, 2)
+	certs[0] = configCert
+	config := &tls.Config{Certificates: certs}
+	srv := NewServer(http.NotFoundHandler(),
+		WithSelfSignedCert(),
+		WithServerOptions(func(s *http.Server) {
+			s.TLSConfig = config
+		}))
+
+	if err := srv.installSelfSignedCert(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(srv.TLSConfig.Certificates) != 2 {
+		t.Fatalf("got %d certificates, want the one of the config and the self-signed one",
+			len(srv.TLSConfig.Certificates))
+	}
+	if srv.TLSConfig == config || len(config.Certificates) != 1 || certs[:2][1].Certificate != nil {
+		t.Fatal("the TLSConfig of the caller was modified")
+	}
+}
@@ -0,0 +1,102 @@
+package httpgrace
+
+import (
+	"context"
+	"os"
+)
+
+// Action defines how the server reacts to a shutdown signal.
+type Action int
+
+const (
+	// Graceful drains the connections before shutting down.
+	Graceful Action = iota
+	// Immediate closes all the connections right away, aborting any graceful
+	// shutdown already in progress.
+	Immediate
+)
+
+// WithSignalAction sets how the server reacts to sig, which also triggers
+// the shutdown if it is not one of the WithSignals ones. Signals are handled
+// while shutting down as well, so that e.g. SIGQUIT can abort a graceful
+// drain started by SIGTERM:
+//
+//	httpgrace.WithSignalAction(syscall.SIGQUIT, httpgrace.Immediate)
+func WithSignalAction(sig os.Signal, action Action) Option {
+	return func(cfg *serverConfig) {
+		if cfg.signalActions == nil {
+			cfg.signalActions = make(map[os.Signal]Action)
+		}
+		cfg.signalActions[sig] = action
+	}
+}
+
+// WithSignalChannel drives the shutdown with the signals received on ch
+// instead of the process signals, which are then not handled at all, e.g. to
+// test the shutdown deterministically or to plug in a custom signal
+// multiplexer. The actions set with WithSignalAction apply to them as well.
+func WithSignalChannel(ch <-chan os.Signal) Option {
+	return func(cfg *serverConfig) {
+		cfg.signalChannel = ch
+	}
+}
+
+// notifySignals returns the signals the server has to be notified of.
+func (cfg *serverConfig) notifySignals() []os.Signal {
+	signals := append([]os.Signal(nil), cfg.signals...)
+	for sig := range cfg.signalActions {
+		signals = append(signals, sig)
+	}
+	return signals
+}
+
+// signalAction returns the action configured for sig (default: Graceful).
+func (cfg *serverConfig) signalAction(sig os.Signal) Action {
+	if action, ok := cfg.signalActions[sig]; ok {
+		return action
+	}
+	return Graceful
+}
+
+// watchSignals handles the signals received while shutting down. Any signal
+// closes the returned interrupt channel, stopping the drain delay, and the
+// ones with the Immediate action abort the graceful shutdown.
+func (s *Server) watchSignals(sigChan <-chan os.Signal, abort context.CancelFunc) (interrupt <-chan struct{}, stop func()) {
+	interruptChan := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		interrupted := false
+		for {
+			var sig os.Signal
+			select {
+			case <-done:
+				return
+			case sig = <-sigChan:
+			case sig = <-s.trigger:
+			}
+
+			if !interrupted {
+				close(interruptChan)
+				interrupted = true
+			}
+
+			if s.config.signalAction(sig) == Immediate {
+				s.abortShutdown(sig, abort)
+				return
+			}
+			s.log().Info("shutdown already in progress", "signal", sig.String())
+		}
+	}()
+
+	return interruptChan, func() { close(done) }
+}
+
+// abortShutdown closes all the connections right away, cancelling the
+// graceful shutdown in progress.
+func (s *Server) abortShutdown(sig os.Signal, abort context.CancelFunc) {
+	s.log().Warn("immediate shutdown, closing all connections", "signal", sig.String())
+	s.immediate.Store(true)
+	abort()
+	s.closeServers()
+}
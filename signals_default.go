@@ -0,0 +1,13 @@
+//go:build !windows
+
+package httpgrace
+
+import (
+	"os"
+	"syscall"
+)
+
+// defaultSignals returns the signals triggering the shutdown by default.
+func defaultSignals() []os.Signal {
+	return []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+}
@@ -0,0 +1,18 @@
+//go:build windows
+
+package httpgrace
+
+import (
+	"os"
+	"syscall"
+)
+
+// defaultSignals returns the signals triggering the shutdown by default.
+// On Windows, os.Interrupt is delivered for CTRL_C_EVENT and CTRL_BREAK_EVENT,
+// and syscall.SIGTERM for CTRL_CLOSE_EVENT, CTRL_LOGOFF_EVENT and
+// CTRL_SHUTDOWN_EVENT, i.e. when the console is closed or the system shuts
+// down. Services stopped by the service control manager receive none of
+// them: see WithContext and Server.Stop to shut down from the service handler.
+func defaultSignals() []os.Signal {
+	return []os.Signal{os.Interrupt, syscall.SIGTERM}
+}
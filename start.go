@@ -0,0 +1,55 @@
+package httpgrace
+
+// Start binds the listener on the server Addr (":http" if empty) and serves
+// in the background, returning once the server is listening. The error the
+// server stops with is then delivered on the Errors channel. Start can only
+// be called once, and returns ErrAlreadyStarted afterwards.
+//
+// Combined with Shutdown or Stop, it gives the server a non-blocking lifecycle:
+//
+//	srv := httpgrace.NewServer(handler,
+//		httpgrace.WithServerOptions(httpgrace.WithAddr(":8080")),
+//	)
+//	if err := srv.Start(); err != nil {
+//		log.Fatal(err)
+//	}
+//	...
+//	err := srv.Stop()
+func (s *Server) Start() error {
+	if !s.started.CompareAndSwap(false, true) {
+		return ErrAlreadyStarted
+	}
+
+	addr := s.Server.Addr
+	if addr == "" {
+		addr = ":http"
+	}
+
+	ln, err := s.listen("tcp", addr)
+	if err != nil {
+		s.errs <- err
+		close(s.errs)
+		return err
+	}
+
+	s.mu.Lock()
+	s.addr = ln.Addr()
+	s.mu.Unlock()
+	// a Stop right after Start must run the shutdown sequence, even if the
+	// serving goroutine has not started yet
+	s.serving.Store(true)
+
+	go func() {
+		defer close(s.errs)
+		defer ln.Close()
+		s.errs <- s.serve(ln, false, "", "")
+	}()
+
+	return nil
+}
+
+// Errors returns the channel receiving the error the server started with
+// Start stops with, nil for a clean shutdown. The channel is closed afterwards.
+func (s *Server) Errors() <-chan error {
+	return s.errs
+}
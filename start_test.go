@@ -0,0 +1,32 @@
+package httpgrace
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestStartStopRunsShutdownSequence(t *testing.T) {
+	for i := range 50 {
+		var ran bool
+		srv := NewServer(http.NotFoundHandler(),
+			WithoutSignals(),
+			WithServerOptions(WithAddr("127.0.0.1:0")),
+			WithPostShutdownHook(func() error {
+				ran = true
+				return nil
+			}))
+
+		if err := srv.Start(); err != nil {
+			t.Fatal(err)
+		}
+		if err := srv.Stop(); err != nil {
+			t.Fatalf("Stop() error = %v", err)
+		}
+		if !ran {
+			t.Fatalf("run %d: post-shutdown hook not run on Stop right after Start", i)
+		}
+		if err := <-srv.Errors(); err != nil {
+			t.Fatalf("Errors() = %v, want nil", err)
+		}
+	}
+}
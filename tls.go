@@ -0,0 +1,128 @@
+package httpgrace
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+)
+
+var errTLSConfigConflict = errors.New("httpgrace: certificate files cannot be used with a TLSConfig setting GetCertificate or GetConfigForClient")
+
+// WithTLSConfig serves TLS using the given configuration, e.g. with in-memory
+// certificates, a minimum version or client certificate verification.
+// The server then serves TLS from any of its serving methods. If certificate
+// files are given as well, as with ListenAndServeTLS, their certificate is
+// added to the configuration as the default one, keeping the other settings.
+// The same applies to a TLSConfig set with WithServerOptions.
+// Certificate files are rejected if the configuration sets GetCertificate or
+// GetConfigForClient, since it would pick the certificates itself.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(cfg *serverConfig) {
+		cfg.tlsConfig = config
+	}
+}
+
+// setupTLS prepares the TLS configuration of the server, returning whether
+// it must serve TLS, and the certificate files still to be passed to ServeTLS.
+func (s *Server) setupTLS(useTLS bool, certFile, keyFile string) (bool, string, string, error) {
+	useTLS = useTLS || s.config.tlsConfig != nil
+
+	if s.config.selfSigned {
+		if certFile != "" || keyFile != "" {
+			return false, "", "", errSelfSignedWithFiles
+		}
+		if err := s.installSelfSignedCert(); err != nil {
+			return false, "", "", err
+		}
+		useTLS = true
+	}
+
+	if s.config.autocert != nil {
+		if certFile != "" || keyFile != "" {
+			return false, "", "", errAutocertWithFiles
+		}
+		s.installAutocert()
+		useTLS = true
+	}
+
+	if certFile != "" {
+		s.checkCertChain(certFile)
+	}
+
+	if s.TLSConfig != nil && (certFile != "" || keyFile != "") {
+		if err := s.addCertFiles(certFile, keyFile); err != nil {
+			return false, "", "", err
+		}
+		certFile, keyFile = "", ""
+	}
+
+	return useTLS, certFile, keyFile, nil
+}
+
+// addCertFiles loads the certificate files into the TLS configuration of
+// the server, as its default certificate, rather than letting ServeTLS
+// replace the configured certificates with them.
+func (s *Server) addCertFiles(certFile, keyFile string) error {
+	if s.TLSConfig.GetCertificate != nil || s.TLSConfig.GetConfigForClient != nil {
+		return errTLSConfigConflict
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return fmt.Errorf("loading certificate files: %w", err)
+	}
+
+	config := s.TLSConfig.Clone()
+	config.Certificates = append([]tls.Certificate{cert}, config.Certificates...)
+	s.TLSConfig = config
+	return nil
+}
+
+// checkCertChain warns if the certificate file appears to be missing the
+// intermediate certificates, i.e. it holds a single certificate that is not
+// self-signed. Errors reading or parsing the file are left to ServeTLS.
+func (s *Server) checkCertChain(certFile string) {
+	data, err := os.ReadFile(certFile)
+	if err != nil {
+		return
+	}
+
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) != 1 || isSelfSigned(certs[0]) {
+		return
+	}
+
+	leaf := certs[0]
+	s.log().Warn("certificate chain appears incomplete, intermediate certificates may be missing",
+		"cert_file", certFile,
+		"subject", leaf.Subject.String(),
+		"issuer", leaf.Issuer.String())
+}
+
+func isSelfSigned(cert *x509.Certificate) bool {
+	if !bytes.Equal(cert.RawIssuer, cert.RawSubject) {
+		return false
+	}
+	return cert.CheckSignatureFrom(cert) == nil
+}
@@ -0,0 +1,175 @@
+package httpgrace
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeCertFiles writes a new certificate and its key to PEM files.
+func writeCertFiles(t *testing.T) (tls.Certificate, string, string) {
+	t.Helper()
+
+	cert, err := generateSelfSignedCert(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key})
+	if err := os.WriteFile(certFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return cert, certFile, keyFile
+}
+
+// peerCert connects to srv with TLS, returning the certificate it served.
+func peerCert(t *testing.T, srv *Server, client *tls.Config) ([]byte, error) {
+	t.Helper()
+
+	conn, err := tls.Dial("tcp", srv.ListenAddr().String(), client)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.ConnectionState().PeerCertificates[0].Raw, nil
+}
+
+func TestServeTLSConfigAndFiles(t *testing.T) {
+	fileCert, certFile, keyFile := writeCertFiles(t)
+	configCert, err := generateSelfSignedCert(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		config   *tls.Config
+		files    bool
+		wantCert []byte
+		wantErr  error
+	}{
+		{
+			name:     "files only",
+			files:    true,
+			wantCert: fileCert.Certificate[0],
+		},
+		{
+			name:     "config only",
+			config:   &tls.Config{Certificates: []tls.Certificate{configCert}},
+			wantCert: configCert.Certificate[0],
+		},
+		{
+			name: "config and files",
+			config: &tls.Config{
+				Certificates: []tls.Certificate{configCert},
+				MinVersion:   tls.VersionTLS13,
+			},
+			files:    true,
+			wantCert: fileCert.Certificate[0],
+		},
+		{
+			name: "config with GetCertificate and files",
+			config: &tls.Config{
+				GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+					return &configCert, nil
+				},
+			},
+			files:   true,
+			wantErr: errTLSConfigConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ready := make(chan struct{})
+			opts := []Option{WithoutSignals(), WithReady(ready)}
+			if tt.config != nil {
+				opts = append(opts, WithTLSConfig(tt.config))
+			}
+			srv := NewServer(http.NotFoundHandler(), opts...)
+
+			ln := listen(t)
+			errs := make(chan error, 1)
+			go func() {
+				if tt.files {
+					errs <- srv.ServeTLS(ln, certFile, keyFile)
+				} else {
+					errs <- srv.Serve(ln)
+				}
+			}()
+
+			if tt.wantErr != nil {
+				if err := waitServe(t, errs); !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ServeTLS() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+
+			<-ready
+			defer func() {
+				if err := srv.Stop(); err != nil {
+					t.Errorf("Stop() error = %v", err)
+				}
+			}()
+
+			got, err := peerCert(t, srv, &tls.Config{InsecureSkipVerify: true})
+			if err != nil {
+				t.Fatalf("TLS handshake failed: %v", err)
+			}
+			if !bytes.Equal(got, tt.wantCert) {
+				t.Fatal("served certificate is not the expected one")
+			}
+
+			if tt.config != nil && tt.config.MinVersion == tls.VersionTLS13 {
+				_, err := peerCert(t, srv, &tls.Config{InsecureSkipVerify: true, MaxVersion: tls.VersionTLS12})
+				if err == nil {
+					t.Fatal("TLS 1.2 handshake succeeded, MinVersion of the config was not kept")
+				}
+			}
+		})
+	}
+}
+
+func TestAddCertFilesKeepsConfig(t *testing.T) {
+	fileCert, certFile, keyFile := writeCertFiles(t)
+	configCert, err := generateSelfSignedCert(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &tls.Config{Certificates: []tls.Certificate{configCert}}
+	srv := NewServer(http.NotFoundHandler(), WithServerOptions(func(s *http.Server) {
+		s.TLSConfig = config
+	}))
+
+	if err := srv.addCertFiles(certFile, keyFile); err != nil {
+		t.Fatal(err)
+	}
+
+	certs := srv.TLSConfig.Certificates
+	if len(certs) != 2 ||
+		!bytes.Equal(certs[0].Certificate[0], fileCert.Certificate[0]) ||
+		!bytes.Equal(certs[1].Certificate[0], configCert.Certificate[0]) {
+		t.Fatal("certificate of the files not added as the default one")
+	}
+	if len(config.Certificates) != 1 {
+		t.Fatal("the TLSConfig of the caller was modified")
+	}
+}
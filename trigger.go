@@ -0,0 +1,50 @@
+package httpgrace
+
+import "os"
+
+// shutdownSignal is an os.Signal used to trigger the shutdown for reasons
+// other than an OS signal.
+type shutdownSignal string
+
+func (s shutdownSignal) String() string { return string(s) }
+func (s shutdownSignal) Signal()        {}
+
+// Sentinel signals reported by WithShutdownNotify when the shutdown was not
+// triggered by an OS signal.
+var (
+	// SignalStop is reported for Server.Shutdown and Server.Stop.
+	SignalStop os.Signal = shutdownSignal("stop")
+	// SignalContextDone is reported when the WithContext context is done.
+	SignalContextDone os.Signal = shutdownSignal("context done")
+	// SignalHealthCheck is reported when WithSelfHealthCheck failed.
+	SignalHealthCheck os.Signal = shutdownSignal("health check failed")
+	// SignalMaxUptime is reported when WithMaxUptime expired.
+	SignalMaxUptime os.Signal = shutdownSignal("max uptime reached")
+	// SignalListenerFailed is reported when one of the listeners of a server
+	// serving on several of them failed.
+	SignalListenerFailed os.Signal = shutdownSignal("listener failed")
+	// SignalRestart is reported when the server shuts down after handing its
+	// listeners over to a new process (see WithGracefulRestart).
+	SignalRestart os.Signal = shutdownSignal("graceful restart")
+	// SignalGroupMemberStopped is reported to the servers of a Group
+	// when one of them stopped.
+	SignalGroupMemberStopped os.Signal = shutdownSignal("group member stopped")
+)
+
+// WithShutdownNotify sets a function called with the signal that triggered
+// the shutdown, as soon as it is received. Shutdowns not triggered by an OS
+// signal report one of the sentinel signals, such as SignalStop.
+func WithShutdownNotify(fn func(os.Signal)) Option {
+	return func(cfg *serverConfig) {
+		cfg.shutdownNotify = fn
+	}
+}
+
+// triggerShutdown starts the graceful shutdown as if sig was received.
+// It does nothing if a shutdown has already been triggered.
+func (s *Server) triggerShutdown(sig os.Signal) {
+	select {
+	case s.trigger <- sig:
+	default:
+	}
+}
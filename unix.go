@@ -0,0 +1,34 @@
+package httpgrace
+
+import (
+	"net/http"
+	"os"
+)
+
+// WithSocketMode sets the file permissions of the Unix sockets created by the
+// server (default: 0660).
+func WithSocketMode(mode os.FileMode) Option {
+	return func(cfg *serverConfig) {
+		cfg.socketMode = mode
+	}
+}
+
+// ListenAndServeUnix starts a non-TLS HTTP server with graceful shutdown on a
+// Unix domain socket. A stale socket file left by a previous process is
+// removed, and the socket file is removed when the server stops.
+// Unix domain sockets are not supported on plan9.
+func ListenAndServeUnix(socketPath string, handler http.Handler, opts ...Option) error {
+	return NewServer(handler, opts...).ListenAndServeUnix(socketPath)
+}
+
+// ListenAndServeUnix starts the server with graceful shutdown on a Unix
+// domain socket. See the package level ListenAndServeUnix.
+func (s *Server) ListenAndServeUnix(socketPath string) error {
+	ln, err := s.listenUnix(socketPath)
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+
+	return s.serve(ln, false, "", "")
+}
@@ -0,0 +1,64 @@
+//go:build !plan9
+
+package httpgrace
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"syscall"
+)
+
+// listenUnix creates a Unix socket listener on path, with the configured
+// permissions, that removes the socket file when closed.
+func (s *Server) listenUnix(path string) (net.Listener, error) {
+	if ln := s.inheritedListener("unix", path); ln != nil {
+		ln.(*net.UnixListener).SetUnlinkOnClose(true)
+		return ln, nil
+	}
+
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	ln.(*net.UnixListener).SetUnlinkOnClose(true)
+
+	if err := os.Chmod(path, s.config.socketMode); err != nil {
+		ln.Close()
+		return nil, err
+	}
+
+	return ln, nil
+}
+
+// removeStaleSocket removes the socket file at path if no one is listening
+// on it anymore.
+func removeStaleSocket(path string) error {
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&fs.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+
+	conn, err := net.Dial("unix", path)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("socket %s is already in use", path)
+	}
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		return err
+	}
+
+	return os.Remove(path)
+}
@@ -0,0 +1,13 @@
+package httpgrace
+
+import (
+	"errors"
+	"net"
+)
+
+var errUnixSocketsUnsupported = errors.New("httpgrace: Unix domain sockets are not supported on plan9")
+
+// listenUnix fails, since plan9 has no Unix domain sockets.
+func (s *Server) listenUnix(path string) (net.Listener, error) {
+	return nil, errUnixSocketsUnsupported
+}
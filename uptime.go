@@ -0,0 +1,31 @@
+package httpgrace
+
+import "time"
+
+// WithMaxUptime triggers a graceful shutdown once the server has been up for d,
+// e.g. to mitigate slow leaks. It assumes that an external supervisor
+// (systemd, Kubernetes, ...) restarts the process afterwards.
+func WithMaxUptime(d time.Duration) Option {
+	return func(cfg *serverConfig) {
+		cfg.maxUptime = d
+	}
+}
+
+// WithMaxLifetime is an alias of WithMaxUptime. The shutdown it triggers is
+// reported as SignalMaxUptime.
+func WithMaxLifetime(d time.Duration) Option {
+	return WithMaxUptime(d)
+}
+
+// startMaxUptimeTimer arms the max uptime timer, which does nothing if the
+// shutdown already started. The caller must stop the returned timer when the
+// server stops.
+func (s *Server) startMaxUptimeTimer() *time.Timer {
+	return time.AfterFunc(s.config.maxUptime, func() {
+		if s.shuttingDown.Load() {
+			return
+		}
+		s.log().Info("max uptime reached", "uptime", s.Uptime())
+		s.triggerShutdown(SignalMaxUptime)
+	})
+}